@@ -2,22 +2,35 @@ package main
 
 import (
 	"context"
+	"errors"
 	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/services"
 	"golang.org/x/time/rate"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	_ "time/tzdata" // даты кампаний вводятся в часовом поясе TZ, в alpine нет zoneinfo
 
 	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/config"
 	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/db"
 	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/handlers"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/metrics"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func main() {
 	cfg := config.LoadConfig()
+
+	// bot migrate … — только миграции, без запуска бота
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+
 	if cfg.TelegramToken == "" {
 		log.Fatal("TELEGRAM_APITOKEN not found in config")
 	}
@@ -28,74 +41,189 @@ func main() {
 	}
 	log.Printf("Authorized as @%s", bot.Self.UserName)
 
-	pub := tgbotapi.NewSetMyCommands(
-		tgbotapi.BotCommand{Command: "start", Description: "Начать работу"},
-		tgbotapi.BotCommand{Command: "draw", Description: "Получить стикерпак"},
-	)
+	pool := db.Connect(cfg)
+	defer pool.Close()
+	migrateOnStart(cfg, pool)
+	repo := repositories.NewRepository(pool)
+	metrics.RegisterPool(pool)
+
+	pub := tgbotapi.NewSetMyCommands(handlers.PublicCommands()...)
 	publicScope := tgbotapi.NewBotCommandScopeDefault()
 	pub.Scope = &publicScope
 	_, _ = bot.Request(pub)
 
-	admin := tgbotapi.NewSetMyCommands(
-		tgbotapi.BotCommand{Command: "start", Description: "Начать работу"},
-		tgbotapi.BotCommand{Command: "packs", Description: "Список стикерпаков"},
-		tgbotapi.BotCommand{Command: "addpack", Description: "Добавить стикерпак"},
-	)
-	adminScope := tgbotapi.NewBotCommandScopeChat(cfg.AdminID)
-	admin.Scope = &adminScope
-	_, _ = bot.Request(admin)
-
-	pool := db.Connect(cfg)
-	defer pool.Close()
+	// Админ из конфига всегда владелец; меню команд — каждому админу под его роль
+	initCtx, cancelInit := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := repo.EnsureOwner(initCtx, cfg.AdminID); err != nil {
+		log.Fatalf("EnsureOwner: %v", err)
+	}
+	admins, err := repo.GetAdmins(initCtx)
+	cancelInit()
+	if err != nil {
+		log.Fatalf("GetAdmins: %v", err)
+	}
+	for _, a := range admins {
+		if err := handlers.SetAdminCommands(bot, a.UserID, a.Role); err != nil {
+			log.Printf("SetAdminCommands(%d): %v", a.UserID, err)
+		}
+	}
 
 	// Глобальный лимит Telegram. Ставим «безопасные» ~28 rps.
 	lim := rate.NewLimiter(rate.Limit(28), 28)
-	sender := services.NewSender(bot, lim)
-
-	h := handlers.NewHandler(bot, sender, pool, cfg)
+	sender := services.NewSender(bot, lim, repo)
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	u.AllowedUpdates = []string{"message", "callback_query"} // меньше шума
-	updates := bot.GetUpdatesChan(u)
+	broadcaster := services.NewBroadcaster(repo, sender)
+	h := handlers.NewHandler(bot, sender, broadcaster, pool, cfg)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// Пул воркеров + очередь (бэкпрешер)
-	const workers = 64
-	jobs := make(chan tgbotapi.Update, 4096)
-	for i := 0; i < workers; i++ {
+	// Очередь апдейтов для пула воркеров: при переполнении апдейты ждут в БД
+	queue := services.NewUpdateQueue(repo, 4096)
+
+	// Служебный HTTP: метрики для Prometheus и пробы для оркестратора
+	health := services.NewHealth(pool.Ping, sender, queue.Len)
+	opsMux := http.NewServeMux()
+	opsMux.Handle("/metrics", metrics.Handler())
+	opsMux.HandleFunc("/healthz", health.Healthz)
+	opsMux.HandleFunc("/readyz", health.Readyz)
+	opsSrv := &http.Server{Addr: cfg.HTTPListen, Handler: opsMux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := opsSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("http server: %v", err)
+		}
+	}()
+	defer opsSrv.Close()
+
+	go queue.Run(ctx)
+
+	// Рассылки (в том числе недосланные до рестарта) и анонсы старта кампаний
+	go broadcaster.Run(ctx)
+	sched := services.NewScheduler(repo, broadcaster)
+	go sched.Run(ctx)
+	// Отложенные сообщения (выигрыш после кубика), в том числе недосланные до рестарта
+	go services.NewOutbox(repo, sender).Run(ctx)
+
+	// Пул воркеров: чаты обрабатываются параллельно, апдейты одного чата — по порядку.
+	// Свой контекст: при остановке пул гасим после того, как перестали принимать апдейты.
+	// Очереди чатов в пуле вместе держат не больше maxPending апдейтов, остальные ждут в очереди апдейтов.
+	const workers, maxPending = 64, 1024
+	workerPool := services.NewWorkerPool(workers, maxPending, func(upd tgbotapi.Update) {
+		// и после паники: повтор того же апдейта упал бы так же
+		defer queue.Done(context.Background(), upd.UpdateID)
+		h.HandleUpdate(upd)
+	})
+	poolCtx, stopPool := context.WithCancel(context.Background())
+	poolDone := make(chan struct{})
+	go func() {
+		workerPool.Run(poolCtx, queue.Jobs())
+		close(poolDone)
+	}()
+
+	allowedUpdates := []string{"message", "callback_query"} // меньше шума
+	// в режиме вебхука updates остаётся nil: апдейты кладёт в очередь сам вебхук
+	var updates tgbotapi.UpdatesChannel
+	var stopReceiving func()
+	if cfg.WebhookURL != "" {
+		wh := services.NewWebhook(bot, cfg.WebhookURL, cfg.WebhookSecret, queue)
+		mux := http.NewServeMux()
+		mux.Handle(wh.Path(), wh)
+		srv := &http.Server{Addr: cfg.WebhookListen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
 		go func() {
-			for upd := range jobs {
-				// защита от паник внутри обработчика
-				func() {
-					defer func() { _ = recover() }()
-					h.HandleUpdate(upd)
-				}()
+			var err error
+			if cfg.WebhookCert != "" {
+				err = srv.ListenAndServeTLS(cfg.WebhookCert, cfg.WebhookKey)
+			} else {
+				err = srv.ListenAndServe()
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("webhook server: %v", err)
 			}
 		}()
+		if err := wh.Register(allowedUpdates); err != nil {
+			log.Fatalf("setWebhook: %v", err)
+		}
+		stopReceiving = func() {
+			// Shutdown дожидается начатых запросов: принятые апдейты успеют записаться в БД
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutdownCtx)
+			// при rolling deploy вебхук уже принадлежит новому экземпляру — тогда WEBHOOK_UNREGISTER=false
+			if cfg.WebhookUnregister {
+				if err := wh.Unregister(); err != nil {
+					log.Printf("deleteWebhook: %v", err)
+				}
+			}
+		}
+		log.Printf("Webhook mode: %s on %s", cfg.WebhookURL, cfg.WebhookListen)
+	} else {
+		// оставшийся от вебхук-режима вебхук не даёт getUpdates работать
+		if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			log.Printf("deleteWebhook: %v", err)
+		}
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		u.AllowedUpdates = allowedUpdates
+		updates = bot.GetUpdatesChan(u)
+		stopReceiving = func() {
+			bot.StopReceivingUpdates()
+			// Апдейты в буфере канала Telegram уже считает доставленными — сохраняем их.
+			// Ответ незавершённого long poll не подтверждён offset'ом и придёт следующему запуску.
+			deadline := time.After(5 * time.Second)
+			for {
+				select {
+				case upd, ok := <-updates:
+					if !ok {
+						return
+					}
+					enqueuePolled(queue, upd)
+				case <-deadline:
+					return
+				}
+			}
+		}
 	}
 
 	log.Println("Bot started")
 
+	// Тик нужен, чтобы /healthz видел живой цикл и в тишине без апдейтов
+	beat := time.NewTicker(5 * time.Second)
+	defer beat.Stop()
+loop:
 	for {
+		health.Beat()
 		select {
+		case <-beat.C:
 		case <-ctx.Done():
-			close(jobs)
-			return
+			break loop
 		case upd, ok := <-updates:
 			if !ok {
 				log.Println("updates channel closed")
-				close(jobs)
-				return
-			}
-			select {
-			case jobs <- upd:
-			default:
-				// Очередь переполнена — дропнем событие (или можно считать метрику)
-				log.Println("updates backlog overflow, dropping update")
+				break loop
 			}
+			enqueuePolled(queue, upd)
 		}
 	}
+
+	// Остановка: перестаём принимать апдейты и сохраняем уже принятые, ждём начатые
+	// обработчики, а не начатое отдаём другим экземплярам (или следующему запуску)
+	log.Println("Shutting down")
+	stopReceiving()
+	stopPool()
+	select {
+	case <-poolDone:
+	case <-time.After(8 * time.Second):
+		log.Println("workers did not finish in time")
+	}
+	queue.Release(context.Background())
+}
+
+// enqueuePolled сохраняет апдейт из long polling. Telegram его уже не повторит,
+// поэтому при недоступной БД он хотя бы уходит воркерам из памяти.
+func enqueuePolled(queue *services.UpdateQueue, upd tgbotapi.Update) {
+	metrics.UpdatesReceived.WithLabelValues("polling").Inc()
+	if err := queue.Push(context.Background(), upd); err != nil {
+		log.Println("enqueue update:", err)
+		queue.Offer(upd)
+	}
 }
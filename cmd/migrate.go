@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/migrations"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/config"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/db"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = "usage: bot migrate [up | down [N] | version | force VERSION]"
+
+// migrateOnStart доводит схему до версии бинарника (или только проверяет её
+// при AUTO_MIGRATE=false) и не даёт стартовать на схеме новее
+func migrateOnStart(cfg *config.Config, pool *pgxpool.Pool) {
+	m, err := db.NewMigrator(pool, migrations.FS)
+	if err != nil {
+		log.Fatalf("load migrations: %v", err)
+	}
+	// ожидание advisory lock, пока мигрирует соседний экземпляр, входит в таймаут
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if !cfg.AutoMigrate {
+		version, err := m.Check(ctx)
+		if err != nil {
+			log.Fatalf("schema check: %v", err)
+		}
+		if version < m.Latest() {
+			log.Fatalf("schema is at %d, this build needs %d: run `bot migrate up`", version, m.Latest())
+		}
+		return
+	}
+	applied, err := m.Up(ctx)
+	if err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+	if applied > 0 {
+		log.Printf("Applied %d migration(s), schema is at %d", applied, m.Latest())
+	}
+}
+
+func runMigrate(cfg *config.Config, args []string) {
+	pool := db.Connect(cfg)
+	defer pool.Close()
+	m, err := db.NewMigrator(pool, migrations.FS)
+	if err != nil {
+		log.Fatalf("load migrations: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("applied %d migration(s)\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("reverted %d migration(s)\n", reverted)
+	case "version":
+		version, err := m.Version(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("schema version %d, latest known %d\n", version, m.Latest())
+	case "force":
+		if len(args) < 2 {
+			log.Fatal(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatal(migrateUsage)
+		}
+		if err := m.Force(ctx, version); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("schema version set to %d\n", version)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
@@ -12,7 +12,7 @@ CREATE TABLE IF NOT EXISTS admin_states (
                                             data TEXT
 );
 
-CREATE TABLE bot_users (
+CREATE TABLE IF NOT EXISTS bot_users (
                            user_id BIGINT PRIMARY KEY,
                            created_at TIMESTAMPTZ DEFAULT now()
 );
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE sticker_packs DROP COLUMN IF EXISTS weight;
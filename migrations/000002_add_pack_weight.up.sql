@@ -0,0 +1,2 @@
+ALTER TABLE sticker_packs
+    ADD COLUMN IF NOT EXISTS weight INT NOT NULL DEFAULT 1 CHECK (weight >= 0);
@@ -0,0 +1,3 @@
+-- NULL — без ограничения количества
+ALTER TABLE sticker_packs
+    ADD COLUMN IF NOT EXISTS stock INT CHECK (stock >= 0);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pack_codes;
+ALTER TABLE sticker_packs DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,15 @@
+-- link  — всем победителям отправляется общий url
+-- codes — каждому победителю выдаётся свой одноразовый код из pack_codes
+ALTER TABLE sticker_packs
+    ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'link' CHECK (kind IN ('link', 'codes'));
+
+CREATE TABLE IF NOT EXISTS pack_codes (
+    id         SERIAL PRIMARY KEY,
+    pack_id    INT NOT NULL REFERENCES sticker_packs (id) ON DELETE CASCADE,
+    code       TEXT NOT NULL,
+    claimed_by BIGINT,
+    claimed_at TIMESTAMPTZ,
+    UNIQUE (pack_id, code)
+);
+
+CREATE INDEX IF NOT EXISTS pack_codes_free_idx ON pack_codes (pack_id) WHERE claimed_by IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE user_claims
+    DROP COLUMN IF EXISTS claimed_at,
+    DROP COLUMN IF EXISTS pack_id;
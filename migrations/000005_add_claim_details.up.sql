@@ -0,0 +1,6 @@
+ALTER TABLE user_claims
+    ADD COLUMN IF NOT EXISTS pack_id    INT REFERENCES sticker_packs (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ;
+
+-- Старые клеймы остаются с NULL: реальное время их получения неизвестно
+ALTER TABLE user_claims ALTER COLUMN claimed_at SET DEFAULT now();
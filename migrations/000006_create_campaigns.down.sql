@@ -0,0 +1,11 @@
+-- Откат возможен, только если каждый пользователь выигрывал не больше одного раза
+-- и имена паков не повторяются между кампаниями
+ALTER TABLE user_claims DROP CONSTRAINT IF EXISTS user_claims_pkey;
+ALTER TABLE user_claims ADD PRIMARY KEY (user_id);
+ALTER TABLE user_claims DROP COLUMN IF EXISTS campaign_id;
+
+ALTER TABLE sticker_packs DROP CONSTRAINT IF EXISTS sticker_packs_campaign_name_key;
+ALTER TABLE sticker_packs ADD CONSTRAINT sticker_packs_name_key UNIQUE (name);
+ALTER TABLE sticker_packs DROP COLUMN IF EXISTS campaign_id;
+
+DROP TABLE IF EXISTS campaigns;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS campaigns (
+    id               SERIAL PRIMARY KEY,
+    name             TEXT NOT NULL,
+    starts_at        TIMESTAMPTZ, -- NULL — черновик, розыгрыш не проводится
+    ends_at          TIMESTAMPTZ, -- NULL — без даты окончания
+    start_text       TEXT,        -- NULL — текст по умолчанию
+    win_text         TEXT,
+    upsell_text      TEXT,
+    sub_channel_id   BIGINT,      -- NULL — канал из конфига
+    sub_channel_link TEXT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Всё, что было до кампаний, становится первой (уже идущей) кампанией
+INSERT INTO campaigns (id, name, starts_at)
+VALUES (1, 'Основной розыгрыш', now())
+ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('campaigns', 'id'), (SELECT MAX(id) FROM campaigns));
+
+ALTER TABLE sticker_packs ADD COLUMN IF NOT EXISTS campaign_id INT REFERENCES campaigns (id);
+UPDATE sticker_packs SET campaign_id = 1 WHERE campaign_id IS NULL;
+ALTER TABLE sticker_packs ALTER COLUMN campaign_id SET NOT NULL;
+ALTER TABLE sticker_packs DROP CONSTRAINT IF EXISTS sticker_packs_name_key;
+ALTER TABLE sticker_packs ADD CONSTRAINT sticker_packs_campaign_name_key UNIQUE (campaign_id, name);
+
+-- Клейм уникален в пределах кампании, а не навсегда
+ALTER TABLE user_claims ADD COLUMN IF NOT EXISTS campaign_id INT REFERENCES campaigns (id);
+UPDATE user_claims SET campaign_id = 1 WHERE campaign_id IS NULL;
+ALTER TABLE user_claims ALTER COLUMN campaign_id SET NOT NULL;
+ALTER TABLE user_claims DROP CONSTRAINT IF EXISTS user_claims_pkey;
+ALTER TABLE user_claims ADD PRIMARY KEY (campaign_id, user_id);
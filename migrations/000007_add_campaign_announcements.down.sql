@@ -0,0 +1,3 @@
+ALTER TABLE campaigns
+    DROP COLUMN IF EXISTS announced_at,
+    DROP COLUMN IF EXISTS announce_text;
@@ -0,0 +1,6 @@
+ALTER TABLE campaigns
+    ADD COLUMN IF NOT EXISTS announce_text TEXT,       -- NULL — текст по умолчанию
+    ADD COLUMN IF NOT EXISTS announced_at  TIMESTAMPTZ; -- когда разослали анонс старта
+
+-- Уже идущие кампании повторно не анонсируем
+UPDATE campaigns SET announced_at = now() WHERE starts_at <= now();
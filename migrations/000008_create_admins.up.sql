@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS admins (
+    user_id    BIGINT PRIMARY KEY,
+    role       TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    added_by   BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id           SERIAL PRIMARY KEY,
+    created_by   BIGINT, -- NULL — системная рассылка (анонс кампании)
+    from_chat_id BIGINT, -- копируемое сообщение админа…
+    message_id   INT,
+    text         TEXT,   -- …или готовый HTML-текст
+    reply_markup JSONB,
+    status       TEXT NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'running', 'done', 'cancelled')),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at   TIMESTAMPTZ,
+    finished_at  TIMESTAMPTZ,
+    CHECK (text IS NOT NULL OR (from_chat_id IS NOT NULL AND message_id IS NOT NULL))
+);
+
+CREATE TABLE IF NOT EXISTS broadcast_deliveries (
+    broadcast_id INT NOT NULL REFERENCES broadcasts (id) ON DELETE CASCADE,
+    user_id      BIGINT NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    error        TEXT,
+    sent_at      TIMESTAMPTZ,
+    PRIMARY KEY (broadcast_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS broadcast_deliveries_pending_idx
+    ON broadcast_deliveries (broadcast_id, user_id) WHERE status = 'pending';
@@ -0,0 +1,3 @@
+ALTER TABLE broadcasts DROP COLUMN IF EXISTS segment_id;
+DROP TABLE IF EXISTS segments;
+ALTER TABLE bot_users DROP COLUMN IF EXISTS language_code;
@@ -0,0 +1,12 @@
+ALTER TABLE bot_users ADD COLUMN IF NOT EXISTS language_code TEXT;
+
+CREATE TABLE IF NOT EXISTS segments (
+    id         SERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    filter     JSONB NOT NULL, -- models.SegmentFilter
+    created_by BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE broadcasts
+    ADD COLUMN IF NOT EXISTS segment_id INT REFERENCES segments (id) ON DELETE SET NULL;
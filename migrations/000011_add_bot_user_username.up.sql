@@ -0,0 +1 @@
+ALTER TABLE bot_users ADD COLUMN IF NOT EXISTS username TEXT;
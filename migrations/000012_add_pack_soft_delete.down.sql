@@ -0,0 +1,5 @@
+-- Корзина не переживает откат: без deleted_at удалённые паки снова стали бы призами
+DELETE FROM sticker_packs WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS sticker_packs_campaign_name_key;
+ALTER TABLE sticker_packs ADD CONSTRAINT sticker_packs_campaign_name_key UNIQUE (campaign_id, name);
+ALTER TABLE sticker_packs DROP COLUMN IF EXISTS deleted_at;
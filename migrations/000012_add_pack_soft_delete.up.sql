@@ -0,0 +1,6 @@
+ALTER TABLE sticker_packs ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Имя уникально только среди неудалённых: в корзине могут лежать тёзки
+ALTER TABLE sticker_packs DROP CONSTRAINT IF EXISTS sticker_packs_campaign_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS sticker_packs_campaign_name_key
+    ON sticker_packs (campaign_id, name) WHERE deleted_at IS NULL;
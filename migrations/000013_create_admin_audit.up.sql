@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS admin_audit (
+    id         BIGSERIAL PRIMARY KEY,
+    admin_id   BIGINT,          -- NULL — действие системы
+    action     TEXT NOT NULL,   -- create / update / delete / restore / purge
+    entity     TEXT NOT NULL,   -- pack / campaign / admin / segment / broadcast
+    entity_id  BIGINT NOT NULL,
+    before     JSONB,           -- снимок строки до изменения, NULL для create
+    after      JSONB,           -- снимок после, NULL для purge и удаления админа
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS admin_audit_entity_idx ON admin_audit (entity, entity_id);
+
+-- Журнал только дополняется: правка и удаление записей запрещены
+CREATE OR REPLACE FUNCTION admin_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'admin_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS admin_audit_no_change ON admin_audit;
+CREATE TRIGGER admin_audit_no_change
+    BEFORE UPDATE OR DELETE ON admin_audit
+    FOR EACH ROW EXECUTE FUNCTION admin_audit_append_only();
+
+DROP TRIGGER IF EXISTS admin_audit_no_truncate ON admin_audit;
+CREATE TRIGGER admin_audit_no_truncate
+    BEFORE TRUNCATE ON admin_audit
+    FOR EACH STATEMENT EXECUTE FUNCTION admin_audit_append_only();
@@ -0,0 +1,11 @@
+-- Апдейты Telegram до обработки: переживают рестарт и переполнение очереди воркеров
+CREATE TABLE IF NOT EXISTS update_queue (
+    update_id    BIGINT PRIMARY KEY,            -- он же ключ дедупликации
+    payload      JSONB NOT NULL,
+    queued       BOOLEAN NOT NULL DEFAULT FALSE, -- уже передан воркерам этого процесса
+    received_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    processed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS update_queue_pending_idx ON update_queue (update_id) WHERE processed_at IS NULL;
+CREATE INDEX IF NOT EXISTS update_queue_processed_idx ON update_queue (processed_at) WHERE processed_at IS NOT NULL;
@@ -0,0 +1,17 @@
+-- Отложенные сообщения (выигрыш и допродажа после кубика): пишутся в транзакции клейма,
+-- отправляются диспетчером, поэтому рестарт не теряет приз
+CREATE TABLE IF NOT EXISTS outbox (
+    id           BIGSERIAL PRIMARY KEY,
+    chat_id      BIGINT NOT NULL,
+    text         TEXT NOT NULL,
+    parse_mode   TEXT NOT NULL DEFAULT '',
+    reply_markup JSONB,
+    send_after   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    attempts     INT NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    status       TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (chat_id, send_after, id) WHERE status = 'pending';
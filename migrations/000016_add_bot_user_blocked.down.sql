@@ -0,0 +1 @@
+ALTER TABLE bot_users DROP COLUMN IF EXISTS blocked_at;
@@ -0,0 +1,2 @@
+-- Пользователь заблокировал бота (403): не шлём ему рассылки, пока он снова не напишет
+ALTER TABLE bot_users ADD COLUMN IF NOT EXISTS blocked_at TIMESTAMPTZ;
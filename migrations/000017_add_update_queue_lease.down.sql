@@ -0,0 +1,2 @@
+ALTER TABLE update_queue ADD COLUMN IF NOT EXISTS queued BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE update_queue DROP COLUMN IF EXISTS lease_until, DROP COLUMN IF EXISTS owner;
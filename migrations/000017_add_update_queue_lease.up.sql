@@ -0,0 +1,8 @@
+-- Апдейт берёт в работу конкретный экземпляр бота на время аренды; при rolling deploy
+-- второй экземпляр не трогает чужие апдейты, пока аренда не истекла
+ALTER TABLE update_queue
+    ADD COLUMN IF NOT EXISTS owner       TEXT,
+    ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ;
+
+-- Владельца прежних «переданных воркерам» строк не знаем — их заберёт любой экземпляр
+ALTER TABLE update_queue DROP COLUMN IF EXISTS queued;
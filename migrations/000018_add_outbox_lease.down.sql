@@ -0,0 +1,10 @@
+UPDATE outbox SET status = 'pending' WHERE status = 'sending';
+
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (chat_id, send_after, id) WHERE status = 'pending';
+
+ALTER TABLE outbox DROP CONSTRAINT IF EXISTS outbox_status_check;
+ALTER TABLE outbox ADD CONSTRAINT outbox_status_check
+    CHECK (status IN ('pending', 'sent', 'failed'));
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS lease_until;
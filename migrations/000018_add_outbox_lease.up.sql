@@ -0,0 +1,11 @@
+-- Сообщение берёт в отправку один экземпляр бота (status = 'sending' до lease_until):
+-- при rolling deploy два экземпляра не отправят его дважды, а брошенное упавшим
+-- экземпляром снова уйдёт, когда аренда истечёт
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ;
+
+ALTER TABLE outbox DROP CONSTRAINT IF EXISTS outbox_status_check;
+ALTER TABLE outbox ADD CONSTRAINT outbox_status_check
+    CHECK (status IN ('pending', 'sending', 'sent', 'failed'));
+
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (chat_id, id) WHERE status IN ('pending', 'sending');
@@ -0,0 +1,11 @@
+UPDATE broadcast_deliveries SET status = 'pending' WHERE status = 'sending';
+
+DROP INDEX IF EXISTS broadcast_deliveries_pending_idx;
+CREATE INDEX IF NOT EXISTS broadcast_deliveries_pending_idx
+    ON broadcast_deliveries (broadcast_id, user_id) WHERE status = 'pending';
+
+ALTER TABLE broadcast_deliveries DROP CONSTRAINT IF EXISTS broadcast_deliveries_status_check;
+ALTER TABLE broadcast_deliveries ADD CONSTRAINT broadcast_deliveries_status_check
+    CHECK (status IN ('pending', 'sent', 'failed'));
+
+ALTER TABLE broadcast_deliveries DROP COLUMN IF EXISTS lease_until;
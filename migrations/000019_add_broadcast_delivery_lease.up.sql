@@ -0,0 +1,12 @@
+-- Получателя рассылки берёт в отправку один экземпляр бота (status = 'sending' до lease_until):
+-- при rolling deploy сообщение не уходит дважды, а брошенное упавшим экземпляром
+-- уйдёт снова, когда аренда истечёт
+ALTER TABLE broadcast_deliveries ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ;
+
+ALTER TABLE broadcast_deliveries DROP CONSTRAINT IF EXISTS broadcast_deliveries_status_check;
+ALTER TABLE broadcast_deliveries ADD CONSTRAINT broadcast_deliveries_status_check
+    CHECK (status IN ('pending', 'sending', 'sent', 'failed'));
+
+DROP INDEX IF EXISTS broadcast_deliveries_pending_idx;
+CREATE INDEX IF NOT EXISTS broadcast_deliveries_pending_idx
+    ON broadcast_deliveries (broadcast_id, user_id) WHERE status IN ('pending', 'sending');
@@ -15,6 +15,18 @@ type Config struct {
 	SubChannelID   int64
 	SubChannelLink string
 
+	// Вебхук вместо long polling; пустой WebhookURL — polling
+	WebhookURL        string
+	WebhookListen     string
+	WebhookSecret     string
+	WebhookCert       string // TLS прямо в боте; пусто — HTTP за обратным прокси
+	WebhookKey        string
+	WebhookUnregister bool // снимать вебхук при остановке
+
+	HTTPListen string // служебный HTTP: /metrics, /healthz, /readyz
+
+	AutoMigrate bool // применять миграции при старте; иначе только проверить версию схемы
+
 	PostgresHost     string
 	PostgresPort     string
 	PostgresUser     string
@@ -38,6 +50,28 @@ func LoadConfig() *Config {
 		log.Fatal("SUB_CHANNEL_ID должен быть числом (-100…): ", err)
 	}
 
+	webhookUnregister := true
+	if v := os.Getenv("WEBHOOK_UNREGISTER"); v != "" {
+		webhookUnregister, err = strconv.ParseBool(v)
+		if err != nil {
+			log.Fatal("WEBHOOK_UNREGISTER должен быть true или false: ", err)
+		}
+	}
+	autoMigrate := true
+	if v := os.Getenv("AUTO_MIGRATE"); v != "" {
+		autoMigrate, err = strconv.ParseBool(v)
+		if err != nil {
+			log.Fatal("AUTO_MIGRATE должен быть true или false: ", err)
+		}
+	}
+	webhookSecret := os.Getenv("WEBHOOK_SECRET")
+	if os.Getenv("WEBHOOK_URL") != "" && !validWebhookSecret(webhookSecret) {
+		log.Fatal("Для WEBHOOK_URL нужен WEBHOOK_SECRET: 1–256 символов A-Z, a-z, 0-9, _ и -")
+	}
+	if (os.Getenv("WEBHOOK_CERT") == "") != (os.Getenv("WEBHOOK_KEY") == "") {
+		log.Fatal("WEBHOOK_CERT и WEBHOOK_KEY задаются вместе")
+	}
+
 	return &Config{
 		TelegramToken:  os.Getenv("TELEGRAM_APITOKEN"),
 		AdminID:        adminID,
@@ -45,6 +79,17 @@ func LoadConfig() *Config {
 		SubChannelID:   subChannelID,
 		SubChannelLink: os.Getenv("SUB_CHANNEL_LINK"),
 
+		WebhookURL:        os.Getenv("WEBHOOK_URL"),
+		WebhookListen:     getenv("WEBHOOK_LISTEN", ":8443"),
+		WebhookSecret:     webhookSecret,
+		WebhookCert:       os.Getenv("WEBHOOK_CERT"),
+		WebhookKey:        os.Getenv("WEBHOOK_KEY"),
+		WebhookUnregister: webhookUnregister,
+
+		HTTPListen: getenv("HTTP_LISTEN", ":9090"),
+
+		AutoMigrate: autoMigrate,
+
 		PostgresHost:     os.Getenv("POSTGRES_HOST"),
 		PostgresPort:     os.Getenv("POSTGRES_PORT"),
 		PostgresUser:     os.Getenv("POSTGRES_USER"),
@@ -52,3 +97,23 @@ func LoadConfig() *Config {
 		PostgresDB:       os.Getenv("POSTGRES_DB"),
 	}
 }
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Telegram принимает secret_token только из этих символов
+func validWebhookSecret(s string) bool {
+	if len(s) == 0 || len(s) > 256 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
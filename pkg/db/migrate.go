@@ -0,0 +1,288 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrSchemaTooNew — базу уже мигрировала более новая версия бота
+	ErrSchemaTooNew = errors.New("schema_too_new")
+	// ErrSchemaDirty — предыдущая миграция (ещё через golang-migrate) оборвалась посередине
+	ErrSchemaDirty = errors.New("schema_dirty")
+)
+
+// Ключ advisory lock: миграции не выполняются двумя экземплярами одновременно
+const migrationLockKey = 7351_0001
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrator применяет встроенные миграции и хранит текущую версию в schema_version
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration // по возрастанию версии
+}
+
+func NewMigrator(pool *pgxpool.Pool, fsys fs.FS) (*Migrator, error) {
+	list, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: list}, nil
+}
+
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Latest — последняя версия, известная этому бинарнику
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// locked держит advisory lock на отдельном соединении, пока выполняется fn
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgx.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	}()
+	return fn(conn.Conn())
+}
+
+// withLock — locked для изменяющих команд: заодно готовит schema_version
+// и переносит версию из golang-migrate
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgx.Conn, version int) error) error {
+	return m.locked(ctx, func(conn *pgx.Conn) error {
+		version, err := prepareVersionTable(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return fn(conn, version)
+	})
+}
+
+// prepareVersionTable создаёт schema_version и возвращает текущую версию.
+// База, которую раньше мигрировали руками через golang-migrate, получает версию
+// из его schema_migrations.
+func prepareVersionTable(ctx context.Context, conn *pgx.Conn) (int, error) {
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			singleton  BOOL PRIMARY KEY DEFAULT TRUE CHECK (singleton),
+			version    INT NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := conn.QueryRow(ctx, `SELECT version FROM schema_version`).Scan(&version)
+	if err == nil {
+		return version, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+
+	version, err = legacyVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	_, err = conn.Exec(ctx, `INSERT INTO schema_version (version) VALUES ($1)`, version)
+	return version, err
+}
+
+// readVersion — текущая версия без записи в базу: schema_version, иначе версия
+// golang-migrate, иначе 0
+func readVersion(ctx context.Context, conn *pgx.Conn) (int, error) {
+	var hasTable bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass('schema_version') IS NOT NULL`).Scan(&hasTable); err != nil {
+		return 0, err
+	}
+	if hasTable {
+		var version int
+		err := conn.QueryRow(ctx, `SELECT version FROM schema_version`).Scan(&version)
+		if err == nil {
+			return version, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, err
+		}
+	}
+	return legacyVersion(ctx, conn)
+}
+
+// legacyVersion — версия из schema_migrations golang-migrate; 0 — его не было
+func legacyVersion(ctx context.Context, conn *pgx.Conn) (int, error) {
+	var hasLegacy bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&hasLegacy); err != nil {
+		return 0, err
+	}
+	if !hasLegacy {
+		return 0, nil
+	}
+	var legacy int64
+	var dirty bool
+	err := conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&legacy, &dirty)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return 0, nil
+	case err != nil:
+		return 0, err
+	case dirty:
+		return 0, fmt.Errorf("%w: golang-migrate stopped at version %d", ErrSchemaDirty, legacy)
+	}
+	return int(legacy), nil
+}
+
+// Version — текущая версия схемы. Только читает: проверка при AUTO_MIGRATE=false
+// не создаёт schema_version. Lock — чтобы дождаться идущей миграции.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var version int
+	err := m.locked(ctx, func(conn *pgx.Conn) error {
+		var err error
+		version, err = readVersion(ctx, conn)
+		return err
+	})
+	return version, err
+}
+
+// Check не даёт стартовать на схеме новее, чем знает бинарник
+func (m *Migrator) Check(ctx context.Context) (int, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if version > m.Latest() {
+		return version, fmt.Errorf("%w: database is at %d, this build knows up to %d", ErrSchemaTooNew, version, m.Latest())
+	}
+	return version, nil
+}
+
+// Up применяет все недостающие миграции; каждая — в своей транзакции вместе со сменой версии
+func (m *Migrator) Up(ctx context.Context) (applied int, err error) {
+	err = m.withLock(ctx, func(conn *pgx.Conn, version int) error {
+		if version > m.Latest() {
+			return fmt.Errorf("%w: database is at %d, this build knows up to %d", ErrSchemaTooNew, version, m.Latest())
+		}
+		for _, mig := range m.migrations {
+			if mig.Version <= version {
+				continue
+			}
+			if err := applyMigration(ctx, conn, mig.Up, mig.Version); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down откатывает steps последних применённых миграций
+func (m *Migrator) Down(ctx context.Context, steps int) (reverted int, err error) {
+	err = m.withLock(ctx, func(conn *pgx.Conn, version int) error {
+		// откат неизвестных миграций не выполнить, а записанная версия оказалась бы неверной
+		if version > m.Latest() {
+			return fmt.Errorf("%w: database is at %d, this build knows up to %d", ErrSchemaTooNew, version, m.Latest())
+		}
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			mig := m.migrations[i]
+			if mig.Version > version {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+			}
+			prev := 0
+			if i > 0 {
+				prev = m.migrations[i-1].Version
+			}
+			if err := applyMigration(ctx, conn, mig.Down, prev); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Force записывает версию без выполнения миграций — после ручной починки схемы
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	return m.withLock(ctx, func(conn *pgx.Conn, _ int) error {
+		_, err := conn.Exec(ctx, `UPDATE schema_version SET version=$1, updated_at=now()`, version)
+		return err
+	})
+}
+
+func applyMigration(ctx context.Context, conn *pgx.Conn, sql string, newVersion int) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		// без аргументов — простой протокол, файл может содержать несколько команд
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `UPDATE schema_version SET version=$1, updated_at=now()`, newVersion)
+		return err
+	})
+}
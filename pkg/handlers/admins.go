@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var roleNames = map[models.AdminRole]string{
+	models.RoleOwner:  "👑 владелец",
+	models.RoleEditor: "✏️ редактор",
+	models.RoleViewer: "👁 наблюдатель",
+}
+
+// Минимальная роль для админских коллбэков по префиксу; всё, чего тут нет, — только для editor+.
+// Таблица перечисляет все известные коллбэки: по ней же строятся метки метрик.
+var callbackRoles = []struct {
+	prefix string
+	role   models.AdminRole
+}{
+	{"cmppacks_", models.RoleViewer},
+	{"cmpstats_", models.RoleViewer},
+	{"trash_", models.RoleViewer},
+	{"cmp_", models.RoleViewer},
+	{"cmpnew", models.RoleEditor},
+	{"cmpaddpack_", models.RoleEditor},
+	{"cmpchan_", models.RoleEditor},
+	{"cmpdates_", models.RoleEditor},
+	{"cmpimport_", models.RoleEditor},
+	{"cmpname_", models.RoleEditor},
+	{"cmptext_", models.RoleEditor},
+	{"pack_", models.RoleEditor},
+	{"edit_", models.RoleEditor},
+	{"del_", models.RoleEditor},
+	{"delok_", models.RoleEditor},
+	{"weight_", models.RoleEditor},
+	{"stock_", models.RoleEditor},
+	{"codes_", models.RoleEditor},
+	{"trashpack_", models.RoleEditor},
+	{"restore_", models.RoleEditor},
+	{"purge_", models.RoleEditor},
+	{"purgeok_", models.RoleEditor},
+	{"imp_", models.RoleEditor},
+	{"exp_", models.RoleEditor},
+	{"bcok_", models.RoleEditor},
+	{"bcseg_", models.RoleEditor},
+	{"bccancel_", models.RoleEditor},
+	{"segnew", models.RoleEditor},
+	{"seg_", models.RoleEditor},
+	{"segdel_", models.RoleEditor},
+	{"admnew", models.RoleOwner},
+	{"adm_", models.RoleOwner},
+	{"admdel_", models.RoleOwner},
+	{"admrole_", models.RoleOwner},
+	{"adm", models.RoleOwner},
+	{"audit_", models.RoleOwner},
+}
+
+// callbackPrefix — известный префикс коллбэка из callbackRoles; "" — неизвестный
+func callbackPrefix(data string) string {
+	for _, c := range callbackRoles {
+		if strings.HasPrefix(data, c.prefix) {
+			return c.prefix
+		}
+	}
+	return ""
+}
+
+// callbackRole — минимальная роль для коллбэка; ok=false — коллбэк публичный
+func callbackRole(data string) (models.AdminRole, bool) {
+	switch data {
+	case "start", "draw", "mypack":
+		return "", false
+	}
+	for _, c := range callbackRoles {
+		if strings.HasPrefix(data, c.prefix) {
+			return c.role, true
+		}
+	}
+	return models.RoleEditor, true
+}
+
+func roleButtons(userID int64) []tgbotapi.InlineKeyboardButton {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, r := range []models.AdminRole{models.RoleOwner, models.RoleEditor, models.RoleViewer} {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(roleNames[r], fmt.Sprintf("admrole_%d_%s", userID, r)))
+	}
+	return row
+}
+
+func (h *Handler) showAdminsList(ctx context.Context, chatID int64) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	list, err := h.service.Repo.GetAdmins(dbctx)
+	if err != nil {
+		log.Println("GetAdmins:", err)
+		return
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, a := range list {
+		label := fmt.Sprintf("%d — %s", a.UserID, roleNames[a.Role])
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("adm_%d", a.UserID))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➕ Добавить админа", "admnew")))
+	msg := tgbotapi.NewMessage(chatID, "Админы:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.send(ctx, msg)
+}
+
+func (h *Handler) handleAdminsCallback(ctx context.Context, q *tgbotapi.CallbackQuery) {
+	chatID := q.Message.Chat.ID
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	switch {
+	case q.Data == "admnew":
+		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{UserID: q.From.ID, State: "adm_wait_id"})
+		h.send(ctx, tgbotapi.NewMessage(chatID,
+			"Перешлите сюда любое сообщение будущего админа или отправьте его числовой Telegram ID."))
+
+	case strings.HasPrefix(q.Data, "admrole_"):
+		idStr, role, _ := strings.Cut(strings.TrimPrefix(q.Data, "admrole_"), "_")
+		userID, _ := strconv.ParseInt(idStr, 10, 64)
+		if _, ok := roleNames[models.AdminRole(role)]; !ok {
+			return
+		}
+		if userID == h.adminID {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Роль владельца из настроек бота менять нельзя."))
+			return
+		}
+		if err := h.service.Repo.UpsertAdmin(dbctx, userID, models.AdminRole(role), q.From.ID); err != nil {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Ошибка: "+err.Error()))
+			return
+		}
+		h.service.InvalidateAdmins()
+		h.syncAdminCommands(ctx, userID, models.AdminRole(role))
+		h.send(ctx, tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("✅ %d теперь %s", userID, roleNames[models.AdminRole(role)])))
+
+	case strings.HasPrefix(q.Data, "admdel_"):
+		userID, _ := strconv.ParseInt(strings.TrimPrefix(q.Data, "admdel_"), 10, 64)
+		if userID == h.adminID {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Владельца из настроек бота удалить нельзя."))
+			return
+		}
+		if err := h.service.Repo.DeleteAdmin(dbctx, userID); err != nil {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Ошибка: "+err.Error()))
+			return
+		}
+		h.service.InvalidateAdmins()
+		h.syncAdminCommands(ctx, userID, "")
+		h.send(ctx, tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ %d больше не админ", userID)))
+
+	case strings.HasPrefix(q.Data, "adm_"):
+		userID, _ := strconv.ParseInt(strings.TrimPrefix(q.Data, "adm_"), 10, 64)
+		mk := tgbotapi.NewInlineKeyboardMarkup(
+			roleButtons(userID),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🗑️ Удалить", fmt.Sprintf("admdel_%d", userID)),
+			))
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Админ %d: выберите роль или удалите.", userID))
+		msg.ReplyMarkup = mk
+		h.send(ctx, msg)
+	}
+}
+
+// Шаг «добавить админа»: ждём пересланное сообщение или ID
+func (h *Handler) handleAdminsDialog(ctx context.Context, m *tgbotapi.Message) {
+	var userID int64
+	if m.ForwardFrom != nil {
+		userID = m.ForwardFrom.ID
+	} else {
+		id, err := strconv.ParseInt(strings.TrimSpace(m.Text), 10, 64)
+		if err != nil || id <= 0 {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID,
+				"Не вижу ID. Если пересланное сообщение скрывает автора, попросите его прислать свой ID."))
+			return
+		}
+		userID = id
+	}
+
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("Какую роль выдать %d?", userID))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(roleButtons(userID))
+	h.send(ctx, msg)
+}
+
+// syncAdminCommands обновляет меню команд в чате админа; role "" — убрать админское меню
+func (h *Handler) syncAdminCommands(ctx context.Context, userID int64, role models.AdminRole) {
+	if err := h.sender.Wait(ctx); err != nil {
+		log.Println("rate wait:", err)
+		return
+	}
+	var err error
+	if role == "" {
+		err = ClearAdminCommands(h.bot, userID)
+	} else {
+		err = SetAdminCommands(h.bot, userID, role)
+	}
+	if err != nil {
+		log.Println("syncAdminCommands:", err)
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const auditPageSize = 10
+
+// Длиннее — обрезаем, чтобы страница журнала влезла в сообщение
+const (
+	maxAuditValue = 80
+	maxAuditText  = 3800 // лимит Telegram — 4096 символов
+)
+
+var auditActionNames = map[string]string{
+	repositories.AuditCreate:  "создал",
+	repositories.AuditUpdate:  "изменил",
+	repositories.AuditDelete:  "удалил",
+	repositories.AuditRestore: "восстановил",
+	repositories.AuditPurge:   "удалил навсегда",
+}
+
+var auditEntityNames = map[string]string{
+	"pack":      "приз",
+	"campaign":  "кампанию",
+	"admin":     "админа",
+	"segment":   "сегмент",
+	"broadcast": "рассылку",
+}
+
+// showAudit — страница журнала, новые записи сверху. Листаем по id записей, а не по
+// смещению: страница, обрезанная по длине сообщения, не сдвигает соседние.
+// older > 0 — записи старше id older, newer > 0 — новее id newer, оба 0 — самые новые.
+func (h *Handler) showAudit(ctx context.Context, chatID int64, older, newer int64) {
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	// берём на одну запись больше, чтобы понять, есть ли следующая страница
+	list, err := h.service.Repo.GetAudit(dbctx, auditPageSize+1, older, newer)
+	if err != nil {
+		log.Println("GetAudit:", err)
+		return
+	}
+	if len(list) == 0 {
+		if older != 0 || newer != 0 {
+			h.showAudit(ctx, chatID, 0, 0)
+			return
+		}
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Журнал пуст"))
+		return
+	}
+	// записи идут от курсора: обрезаем по длине сообщения дальний от него край
+	var entries []string
+	size := 0
+	for _, e := range list[:min(len(list), auditPageSize)] {
+		entry := formatAuditEntry(e) + "\n"
+		if len(entries) > 0 && size+len(entry) > maxAuditText {
+			break
+		}
+		entries = append(entries, entry)
+		size += len(entry)
+	}
+	page := list[:len(entries)]
+	more := len(page) < len(list)
+	hasNewer, hasOlder := older > 0, more
+	if newer > 0 {
+		slices.Reverse(page)
+		slices.Reverse(entries)
+		hasNewer, hasOlder = more, true
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	if hasNewer {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("⬅️ Новее", fmt.Sprintf("audit_n%d", page[0].ID)))
+	}
+	if hasOlder {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("Старше ➡️", fmt.Sprintf("audit_o%d", page[len(page)-1].ID)))
+	}
+	msg := tgbotapi.NewMessage(chatID, strings.Join(entries, ""))
+	msg.ParseMode = tgbotapi.ModeHTML
+	if len(row) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(row)
+	}
+	h.send(ctx, msg)
+}
+
+func formatAuditEntry(e models.AuditEntry) string {
+	who := "система"
+	if e.AdminID != nil {
+		who = fmt.Sprintf("<code>%d</code>", *e.AdminID)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>%s</b> %s %s %s #%d\n",
+		e.CreatedAt.In(time.Local).Format(campaignTimeLayout), who,
+		orDefault(auditActionNames[e.Action], e.Action), orDefault(auditEntityNames[e.Entity], e.Entity), e.EntityID)
+	for _, line := range auditDiff(e.Before, e.After) {
+		b.WriteString("  " + line + "\n")
+	}
+	return b.String()
+}
+
+// auditDiff — изменившиеся поля снимков в виде «поле: было → стало»
+func auditDiff(before, after []byte) []string {
+	var was, now map[string]any
+	_ = json.Unmarshal(before, &was)
+	_ = json.Unmarshal(after, &now)
+
+	keys := make(map[string]struct{})
+	for k := range was {
+		keys[k] = struct{}{}
+	}
+	for k := range now {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var lines []string
+	for _, k := range sorted {
+		a, aok := was[k]
+		c, cok := now[k]
+		av, cv := auditValue(a), auditValue(c)
+		if aok && cok && av == cv {
+			continue
+		}
+		switch {
+		case was == nil:
+			if c == nil {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", html.EscapeString(k), html.EscapeString(cv)))
+		case now == nil:
+			lines = append(lines, fmt.Sprintf("%s: %s", html.EscapeString(k), html.EscapeString(av)))
+		default:
+			lines = append(lines, fmt.Sprintf("%s: %s → %s", html.EscapeString(k), html.EscapeString(av), html.EscapeString(cv)))
+		}
+	}
+	return lines
+}
+
+func auditValue(v any) string {
+	if v == nil {
+		return "—"
+	}
+	var s string
+	if str, ok := v.(string); ok {
+		s = str
+	} else {
+		data, _ := json.Marshal(v)
+		s = string(data)
+	}
+	if r := []rune(s); len(r) > maxAuditValue {
+		s = string(r[:maxAuditValue]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (h *Handler) startBroadcast(ctx context.Context, chatID, userID int64) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{UserID: userID, State: "bc_wait_message"})
+	h.send(ctx, tgbotapi.NewMessage(chatID,
+		"Отправьте или перешлите сообщение для рассылки — оно будет скопировано всем пользователям как есть."))
+}
+
+// Сообщение для рассылки получено: сохраняем черновик и показываем превью
+func (h *Handler) handleBroadcastMessage(ctx context.Context, m *tgbotapi.Message) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	id, err := h.service.Repo.CreateBroadcastDraft(dbctx, m.From.ID, m.Chat.ID, m.MessageID)
+	if err != nil {
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+		return
+	}
+	_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	total, err := h.service.Repo.CountBotUsers(dbctx)
+	if err != nil {
+		log.Println("CountBotUsers:", err)
+	}
+
+	h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Превью рассылки:"))
+	if _, err := h.sender.Send(ctx, tgbotapi.NewCopyMessage(m.Chat.ID, m.Chat.ID, m.MessageID)); err != nil {
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Это сообщение нельзя скопировать: "+err.Error()))
+		return
+	}
+	msg := tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("Отправить рассылку #%d? Всего пользователей: %d.", id, total))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Всем", fmt.Sprintf("bcok_%d_0", id)),
+			tgbotapi.NewInlineKeyboardButtonData("🎯 Сегменту…", fmt.Sprintf("bcseg_%d", id)),
+		),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("bccancel_%d", id))),
+	)
+	h.send(ctx, msg)
+}
+
+// Выбор сегмента для черновика: у каждого — число получателей на сейчас
+func (h *Handler) showBroadcastSegments(ctx context.Context, chatID int64, id int) {
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	list, err := h.service.Repo.GetSegments(dbctx)
+	if err != nil {
+		log.Println("GetSegments:", err)
+		return
+	}
+	if len(list) == 0 {
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Сегментов пока нет — создайте их в /addsegment."))
+		return
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, s := range list {
+		total, err := h.service.Repo.CountSegment(dbctx, s.Filter)
+		if err != nil {
+			log.Println("CountSegment:", err)
+			return
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("%s (%d)", s.Name, total), fmt.Sprintf("bcok_%d_%d", id, s.ID))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("bccancel_%d", id))))
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Кому отправить рассылку #%d? В скобках — получателей сейчас.", id))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.send(ctx, msg)
+}
+
+func (h *Handler) handleBroadcastCallback(ctx context.Context, q *tgbotapi.CallbackQuery) {
+	chatID := q.Message.Chat.ID
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	switch {
+	case strings.HasPrefix(q.Data, "bcok_"):
+		idStr, segStr, _ := strings.Cut(strings.TrimPrefix(q.Data, "bcok_"), "_")
+		id, _ := strconv.Atoi(idStr)
+		segmentID, _ := strconv.Atoi(segStr)
+		total, err := h.service.Repo.StartBroadcast(dbctx, id, segmentID)
+		if errors.Is(err, repositories.ErrBroadcastNotDraft) {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Эта рассылка уже запущена или отменена."))
+			return
+		}
+		if errors.Is(err, repositories.ErrNoSegment) {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Сегмент не найден — выберите другой."))
+			return
+		}
+		if err != nil {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Ошибка: "+err.Error()))
+			return
+		}
+		h.broadcaster.Wake()
+		h.send(ctx, tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("🚀 Рассылка #%d запущена, получателей: %d. Пришлю прогресс и итог.", id, total)))
+
+	case strings.HasPrefix(q.Data, "bcseg_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "bcseg_"))
+		h.showBroadcastSegments(ctx, chatID, id)
+
+	case strings.HasPrefix(q.Data, "bccancel_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "bccancel_"))
+		if err := h.service.Repo.CancelBroadcast(dbctx, id); err != nil {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Рассылка уже завершена или отменена."))
+			return
+		}
+		h.send(ctx, tgbotapi.NewMessage(chatID, fmt.Sprintf("⏹ Рассылка #%d отменена", id)))
+	}
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/services"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Тексты по умолчанию — для кампаний, где админ их не переопределил
+const (
+	defaultStartText = "🎯<b><u>Готов испытать свою удачу?</u></b>\n" +
+		"Запускай Колесо Фортуны и забирай один из <i>фирменных ультра-брутальных</i> стикерпаков <b>TWILIGHT HAMMER!</b>\n" +
+		"☸️<i>Крути колесо, боец! Забери свой трофей!</i>"
+
+	defaultWinText = "😎<b>НИШТЯК!</b> Ты залутал крутой стикерпак!\n" +
+		"⚔️Теперь у тебя в руках оружие для чатов — <i>бей словами, жги эмоциями, взрывай переписки!</i>"
+
+	defaultUpsellText = "⚡️<u>Попытка была одна — и Фортуна уже выбрала стикерпак под твой стиль!</u>\n" +
+		"🔄Хочешь другой? Тогда заказывай нашу броню TWILIGHT HAMMER и получай в бонус фирменный стикерпак, который идёт в комплекте с экипировкой.\n\n" +
+		"<b>Заказать можешь тут:</b>\n" +
+		"🟣<b><a href=\"https://www.wildberries.ru/brands/311439225-twilight-hammer\">WILDBERRIES</a></b>\n" +
+		"🔵<b><a href=\"https://vk.com/t.hammer.clan\">VKONTAKTE</a></b>"
+)
+
+// Формат дат кампании во вводе и выводе (часовой пояс процесса, см. TZ)
+const campaignTimeLayout = "02.01.2006 15:04"
+
+const campaignDatesPrompt = "Отправьте даты в формате «ДД.ММ.ГГГГ ЧЧ:ММ - ДД.ММ.ГГГГ ЧЧ:ММ».\n" +
+	"Без второй даты кампания идёт бессрочно, «-» — снять даты (черновик)."
+
+var campaignTextNames = map[string]string{
+	repositories.CampaignTextStart:    "стартовый текст",
+	repositories.CampaignTextWin:      "текст выигрыша",
+	repositories.CampaignTextUpsell:   "текст допродажи",
+	repositories.CampaignTextAnnounce: "текст анонса старта",
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// Канал для проверки подписки: свой у кампании или общий из конфига
+func (h *Handler) subChannel(c models.Campaign) (int64, string) {
+	if c.SubChannelID != 0 {
+		return c.SubChannelID, c.SubChannelLink
+	}
+	return h.subChannelID, h.subChannelLink
+}
+
+// Для команд /packs и /addpack: работаем с идущей кампанией
+func (h *Handler) currentCampaignForAdmin(ctx context.Context, chatID int64) (models.Campaign, bool) {
+	dbctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	camp, err := h.service.Repo.GetCurrentCampaign(dbctx)
+	if errors.Is(err, repositories.ErrNoCampaign) {
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Сейчас нет идущей кампании. Выберите кампанию в /campaigns."))
+		return camp, false
+	}
+	if err != nil {
+		log.Println("GetCurrentCampaign:", err)
+		return camp, false
+	}
+	return camp, true
+}
+
+func (h *Handler) showCampaignsList(ctx context.Context, chatID int64) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	list, err := h.service.Repo.GetCampaigns(dbctx)
+	if err != nil {
+		log.Println("GetCampaigns:", err)
+		return
+	}
+	now := time.Now()
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, c := range list {
+		label := fmt.Sprintf("[%d] %s — %s", c.ID, c.Name, services.StatusOf(c, now))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("cmp_%d", c.ID))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➕ Новая кампания", "cmpnew")))
+	msg := tgbotapi.NewMessage(chatID, "Кампании:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.send(ctx, msg)
+}
+
+func (h *Handler) showCampaign(ctx context.Context, chatID int64, id int) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	c, err := h.service.Repo.GetCampaign(dbctx, id)
+	if err != nil {
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Ошибка: "+err.Error()))
+		return
+	}
+
+	channelID, channelLink := h.subChannel(c)
+	text := fmt.Sprintf("<b>[%d] %s</b>\nСтатус: %s\nНачало: %s\nОкончание: %s\nКанал: %s (%d)",
+		c.ID, html.EscapeString(c.Name), services.StatusOf(c, time.Now()),
+		formatCampaignTime(c.StartsAt, "не задано"), formatCampaignTime(c.EndsAt, "бессрочно"),
+		html.EscapeString(channelLink), channelID)
+
+	mk := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📦 Призы", fmt.Sprintf("cmppacks_%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData("➕ Приз", fmt.Sprintf("cmpaddpack_%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData("📥 Импорт", fmt.Sprintf("cmpimport_%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData("📊 Статистика", fmt.Sprintf("cmpstats_%d", id)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Название", fmt.Sprintf("cmpname_%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData("🕒 Даты", fmt.Sprintf("cmpdates_%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData("📣 Канал", fmt.Sprintf("cmpchan_%d", id)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📝 Старт", fmt.Sprintf("cmptext_%s_%d", repositories.CampaignTextStart, id)),
+			tgbotapi.NewInlineKeyboardButtonData("📝 Выигрыш", fmt.Sprintf("cmptext_%s_%d", repositories.CampaignTextWin, id)),
+			tgbotapi.NewInlineKeyboardButtonData("📝 Допродажа", fmt.Sprintf("cmptext_%s_%d", repositories.CampaignTextUpsell, id)),
+			tgbotapi.NewInlineKeyboardButtonData("📝 Анонс", fmt.Sprintf("cmptext_%s_%d", repositories.CampaignTextAnnounce, id)),
+		))
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = mk
+	h.send(ctx, msg)
+}
+
+func (h *Handler) handleCampaignCallback(ctx context.Context, q *tgbotapi.CallbackQuery) {
+	chatID := q.Message.Chat.ID
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	setState := func(state, data, prompt string) {
+		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{UserID: q.From.ID, State: state, Data: data})
+		h.send(ctx, tgbotapi.NewMessage(chatID, prompt))
+	}
+
+	switch {
+	case q.Data == "cmpnew":
+		setState("cmp_wait_name", "", "Отправьте название новой кампании:")
+
+	case strings.HasPrefix(q.Data, "cmppacks_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "cmppacks_"))
+		h.showPacksList(ctx, chatID, id)
+
+	case strings.HasPrefix(q.Data, "cmpstats_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "cmpstats_"))
+		camp, err := h.service.Repo.GetCampaign(dbctx, id)
+		if err != nil {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Кампания не найдена"))
+			return
+		}
+		h.showStats(ctx, chatID, camp)
+
+	case strings.HasPrefix(q.Data, "cmpaddpack_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "cmpaddpack_"))
+		h.startAddPack(ctx, chatID, q.From.ID, id)
+
+	case strings.HasPrefix(q.Data, "cmpimport_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "cmpimport_"))
+		h.startImportPacks(ctx, chatID, q.From.ID, id)
+
+	case strings.HasPrefix(q.Data, "cmpname_"):
+		setState("cmp_wait_rename", strings.TrimPrefix(q.Data, "cmpname_"), "Отправьте новое название кампании:")
+
+	case strings.HasPrefix(q.Data, "cmpdates_"):
+		setState("cmp_wait_dates", strings.TrimPrefix(q.Data, "cmpdates_"), campaignDatesPrompt)
+
+	case strings.HasPrefix(q.Data, "cmpchan_"):
+		setState("cmp_wait_channel", strings.TrimPrefix(q.Data, "cmpchan_"),
+			"Отправьте ID канала и ссылку через пробел, например «-1001234567890 @channel».\n"+
+				"«-» — канал из настроек бота.")
+
+	case strings.HasPrefix(q.Data, "cmptext_"):
+		kind, id, _ := strings.Cut(strings.TrimPrefix(q.Data, "cmptext_"), "_")
+		setState("cmp_wait_text", id+"|"+kind,
+			"Отправьте "+campaignTextNames[kind]+" (HTML-разметка Telegram; пришлю предпросмотр) или «-», чтобы вернуть текст по умолчанию.")
+
+	case strings.HasPrefix(q.Data, "cmp_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "cmp_"))
+		h.showCampaign(ctx, chatID, id)
+	}
+}
+
+func (h *Handler) handleCampaignDialog(ctx context.Context, m *tgbotapi.Message, st models.AdminState) {
+	// до dbctx: предпросмотр ждёт лимит чата
+	if st.State == "cmp_wait_text" && !h.previewCampaignText(ctx, m) {
+		return
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	reply := func(text string) {
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, text))
+	}
+
+	var (
+		id  int
+		err error
+	)
+	switch st.State {
+	case "cmp_wait_name":
+		id, err = h.service.Repo.CreateCampaign(dbctx, m.Text)
+
+	case "cmp_wait_rename":
+		id, _ = strconv.Atoi(st.Data)
+		err = h.service.Repo.UpdateCampaignName(dbctx, id, m.Text)
+
+	case "cmp_wait_dates":
+		id, _ = strconv.Atoi(st.Data)
+		startsAt, endsAt, perr := parseCampaignDates(m.Text)
+		if perr != nil {
+			reply("Не понял даты: " + perr.Error() + "\n" + campaignDatesPrompt)
+			return
+		}
+		err = h.service.Repo.UpdateCampaignSchedule(dbctx, id, startsAt, endsAt)
+
+	case "cmp_wait_channel":
+		id, _ = strconv.Atoi(st.Data)
+		channelID, link, perr := parseCampaignChannel(m.Text)
+		if perr != nil {
+			reply("ID канала должен быть числом (-100…)")
+			return
+		}
+		err = h.service.Repo.UpdateCampaignChannel(dbctx, id, channelID, link)
+
+	case "cmp_wait_text":
+		idStr, kind, _ := strings.Cut(st.Data, "|")
+		id, _ = strconv.Atoi(idStr)
+		text := m.Text
+		if strings.TrimSpace(text) == "-" {
+			text = ""
+		}
+		err = h.service.Repo.UpdateCampaignText(dbctx, id, kind, text)
+
+	default:
+		return
+	}
+
+	if err != nil {
+		reply("Ошибка: " + err.Error())
+		return
+	}
+	_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	reply("✅ Сохранено")
+	h.showCampaign(ctx, m.Chat.ID, id)
+}
+
+// previewCampaignText отправляет админу новый текст кампании с HTML-разметкой, как его
+// увидят пользователи. Битый тег сломал бы каждое такое сообщение, поэтому сохраняем
+// только текст, который Telegram принял.
+func (h *Handler) previewCampaignText(ctx context.Context, m *tgbotapi.Message) bool {
+	if strings.TrimSpace(m.Text) == "-" {
+		return true
+	}
+	preview := tgbotapi.NewMessage(m.Chat.ID, m.Text)
+	preview.ParseMode = tgbotapi.ModeHTML
+	if _, err := h.sender.Send(ctx, preview); err != nil {
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID,
+			"Telegram не принял текст: "+err.Error()+"\nИсправьте разметку и отправьте снова или «-»."))
+		return false
+	}
+	return true
+}
+
+func formatCampaignTime(t *time.Time, empty string) string {
+	if t == nil {
+		return empty
+	}
+	return t.In(time.Local).Format(campaignTimeLayout)
+}
+
+// parseCampaignDates разбирает «начало - конец»; конец необязателен, «-» — без дат
+func parseCampaignDates(text string) (startsAt, endsAt *time.Time, err error) {
+	text = strings.TrimSpace(text)
+	if text == "-" {
+		return nil, nil, nil
+	}
+	startStr, endStr, hasEnd := strings.Cut(text, " - ")
+	start, err := time.ParseInLocation(campaignTimeLayout, strings.TrimSpace(startStr), time.Local)
+	if err != nil {
+		return nil, nil, err
+	}
+	startsAt = &start
+	if hasEnd {
+		end, err := time.ParseInLocation(campaignTimeLayout, strings.TrimSpace(endStr), time.Local)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !end.After(start) {
+			return nil, nil, errors.New("окончание раньше начала")
+		}
+		endsAt = &end
+	}
+	return startsAt, endsAt, nil
+}
+
+// parseCampaignChannel: «ID ссылка»; «-» — вернуть канал из конфига (ID 0, без ссылки)
+func parseCampaignChannel(text string) (int64, string, error) {
+	text = strings.TrimSpace(text)
+	if text == "-" {
+		return 0, "", nil
+	}
+	idStr, link, _ := strings.Cut(text, " ")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	return id, strings.TrimSpace(link), nil
+}
+
+// formatCountdown: «2 д 5 ч», «3 ч 15 мин», «12 мин»
+func formatCountdown(d time.Duration) string {
+	if d < time.Minute {
+		return "меньше минуты"
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	mins := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%d д %d ч", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%d ч %d мин", hours, mins)
+	default:
+		return fmt.Sprintf("%d мин", mins)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/services"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type adminCommand struct {
+	tgbotapi.BotCommand
+	role models.AdminRole // минимальная роль
+}
+
+// Админские команды в порядке показа в меню Telegram
+var adminCommands = []adminCommand{
+	{tgbotapi.BotCommand{Command: "start", Description: "Начать работу"}, models.RoleViewer},
+	{tgbotapi.BotCommand{Command: "draw", Description: "Получить стикерпак"}, models.RoleViewer},
+	{tgbotapi.BotCommand{Command: "mypack", Description: "Мой стикерпак"}, models.RoleViewer},
+	{tgbotapi.BotCommand{Command: "packs", Description: "Список стикерпаков"}, models.RoleViewer},
+	{tgbotapi.BotCommand{Command: "campaigns", Description: "Кампании"}, models.RoleViewer},
+	{tgbotapi.BotCommand{Command: "stats", Description: "Статистика"}, models.RoleViewer},
+	{tgbotapi.BotCommand{Command: "addpack", Description: "Добавить стикерпак"}, models.RoleEditor},
+	{tgbotapi.BotCommand{Command: "importpacks", Description: "Импорт стикерпаков из файла"}, models.RoleEditor},
+	{tgbotapi.BotCommand{Command: "broadcast", Description: "Рассылка"}, models.RoleEditor},
+	{tgbotapi.BotCommand{Command: "segments", Description: "Сегменты аудитории"}, models.RoleEditor},
+	{tgbotapi.BotCommand{Command: "addsegment", Description: "Новый сегмент"}, models.RoleEditor},
+	{tgbotapi.BotCommand{Command: "export", Description: "Выгрузка пользователей"}, models.RoleEditor},
+	{tgbotapi.BotCommand{Command: "admins", Description: "Админы"}, models.RoleOwner},
+	{tgbotapi.BotCommand{Command: "audit", Description: "Журнал действий"}, models.RoleOwner},
+}
+
+func PublicCommands() []tgbotapi.BotCommand {
+	return []tgbotapi.BotCommand{
+		{Command: "start", Description: "Начать работу"},
+		{Command: "draw", Description: "Получить стикерпак"},
+		{Command: "mypack", Description: "Мой стикерпак"},
+	}
+}
+
+// AdminCommands — команды, доступные роли
+func AdminCommands(role models.AdminRole) []tgbotapi.BotCommand {
+	var list []tgbotapi.BotCommand
+	for _, c := range adminCommands {
+		if services.Allows(role, c.role) {
+			list = append(list, c.BotCommand)
+		}
+	}
+	return list
+}
+
+// commandRole — минимальная роль для команды; ok=false — такой админской команды нет
+func commandRole(cmd string) (models.AdminRole, bool) {
+	for _, c := range adminCommands {
+		if c.Command == cmd {
+			return c.role, true
+		}
+	}
+	return "", false
+}
+
+// SetAdminCommands показывает админу в его чате меню команд под его роль
+func SetAdminCommands(bot *tgbotapi.BotAPI, userID int64, role models.AdminRole) error {
+	cfg := tgbotapi.NewSetMyCommands(AdminCommands(role)...)
+	scope := tgbotapi.NewBotCommandScopeChat(userID)
+	cfg.Scope = &scope
+	_, err := bot.Request(cfg)
+	return err
+}
+
+// ClearAdminCommands возвращает бывшему админу обычное меню
+func ClearAdminCommands(bot *tgbotapi.BotAPI, userID int64) error {
+	_, err := bot.Request(tgbotapi.NewDeleteMyCommandsWithScope(tgbotapi.NewBotCommandScopeChat(userID)))
+	return err
+}
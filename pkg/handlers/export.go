@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/services"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var exportFormats = map[string]func(io.Writer) (services.ExportWriter, error){
+	"csv":  services.NewCSVExport,
+	"xlsx": services.NewXLSXExport,
+}
+
+func (h *Handler) showExportMenu(ctx context.Context, chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, "Выгрузка пользователей и призов. Формат:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("CSV", "exp_csv"),
+		tgbotapi.NewInlineKeyboardButtonData("XLSX", "exp_xlsx"),
+	))
+	h.send(ctx, msg)
+}
+
+func (h *Handler) sendExport(ctx context.Context, chatID int64, format string) {
+	newWriter, ok := exportFormats[format]
+	if !ok {
+		return
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var buf bytes.Buffer
+	w, err := newWriter(&buf)
+	if err == nil {
+		err = h.service.Repo.ExportRows(dbctx, w.WriteRow)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		log.Println("export:", err)
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Ошибка выгрузки: "+err.Error()))
+		return
+	}
+
+	name := fmt.Sprintf("users_%s.%s", time.Now().Format("2006-01-02"), format)
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: buf.Bytes()})
+	if _, err := h.sender.Send(ctx, doc); err != nil {
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Не удалось отправить файл: "+err.Error()))
+	}
+}
@@ -3,15 +3,20 @@ package handlers
 import (
 	"context"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/config"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/metrics"
 	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
 	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
 	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/services"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"html"
+	"io"
 	"log"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -23,18 +28,20 @@ var StartJPG []byte
 type Handler struct {
 	bot            *tgbotapi.BotAPI
 	sender         *services.Sender
+	broadcaster    *services.Broadcaster
 	service        *services.Service
-	adminID        int64
+	adminID        int64 // владелец из конфига: его нельзя удалить или понизить
 	shopURL        string
 	subChannelID   int64
 	subChannelLink string
 }
 
-func NewHandler(bot *tgbotapi.BotAPI, sender *services.Sender, db *pgxpool.Pool, cfg *config.Config) *Handler {
+func NewHandler(bot *tgbotapi.BotAPI, sender *services.Sender, broadcaster *services.Broadcaster, db *pgxpool.Pool, cfg *config.Config) *Handler {
 	repo := repositories.NewRepository(db)
 	return &Handler{
 		bot:            bot,
 		sender:         sender,
+		broadcaster:    broadcaster,
 		service:        services.NewService(repo),
 		adminID:        cfg.AdminID,
 		shopURL:        cfg.ShopURL,
@@ -43,66 +50,119 @@ func NewHandler(bot *tgbotapi.BotAPI, sender *services.Sender, db *pgxpool.Pool,
 	}
 }
 
+// send отправляет сообщение и логирует неудачу: Sender уже повторил 429 и отметил
+// заблокировавших бота, отвечать пользователю тут нечем
+func (h *Handler) send(ctx context.Context, c tgbotapi.Chattable) {
+	if _, err := h.sender.Send(ctx, c); err != nil {
+		chatID, _ := services.ChatID(c)
+		log.Printf("send to %d: %v", chatID, err)
+	}
+}
+
 func (h *Handler) HandleUpdate(upd tgbotapi.Update) {
 	// базовый контекст на обработку одного апдейта
 	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
 	defer cancel()
+	defer func(start time.Time) {
+		metrics.HandlerDuration.WithLabelValues(updateRoute(upd)).Observe(time.Since(start).Seconds())
+	}(time.Now())
 
 	switch {
 	case upd.Message != nil:
 		m := upd.Message
+		if m.From == nil {
+			return
+		}
+		// всё, что админ поменяет в этом апдейте, попадёт в admin_audit от его имени
+		ctx = repositories.WithActor(ctx, m.From.ID)
+		role := h.service.AdminRole(ctx, m.From.ID)
 
 		// Сначала админские команды
-		if m.IsCommand() && m.From != nil && m.From.ID == h.adminID {
-			h.handleAdminCommand(ctx, m)
+		if m.IsCommand() && role != "" {
+			h.handleAdminCommand(ctx, m, role)
 			return
 		}
 
 		// Пользовательские команды
-		if m.IsCommand() && m.From != nil && m.From.ID != h.adminID {
+		if m.IsCommand() {
 			switch m.Command() {
 			case "draw":
 				h.processDraw(ctx, m.Chat.ID, m.From.ID)
 				return
 			case "start":
-				h.sendStartMessage(ctx, m.Chat.ID)
+				h.sendStartMessage(ctx, m.Chat.ID, m.From)
+				return
+			case "mypack":
+				h.sendMyPack(ctx, m.Chat.ID, m.From.ID)
 				return
 			}
 		}
 
 		// Диалог админа — только для админа (чтобы не бить БД по каждому юзеру)
-		if m.From != nil && m.From.ID == h.adminID {
-			h.handleAdminDialog(ctx, m)
+		if role != "" {
+			h.handleAdminDialog(ctx, m, role)
 		}
 
 	case upd.CallbackQuery != nil:
+		if upd.CallbackQuery.From != nil {
+			ctx = repositories.WithActor(ctx, upd.CallbackQuery.From.ID)
+		}
 		h.handleCallback(ctx, upd.CallbackQuery)
 	}
 }
 
-func (h *Handler) sendStartMessage(ctx context.Context, chatID int64) {
+// updateRoute — метка для метрик: команда или префикс коллбэка без ID
+func updateRoute(upd tgbotapi.Update) string {
+	switch {
+	case upd.Message != nil && upd.Message.IsCommand():
+		cmd := upd.Message.Command()
+		if _, ok := commandRole(cmd); ok {
+			return "/" + cmd
+		}
+		return "/other"
+	case upd.Message != nil:
+		return "message"
+	case upd.CallbackQuery != nil:
+		// data присылает клиент: в метку идут только известные коллбэки,
+		// «cmpstats_12» → «cb:cmpstats», остальное — «callback»
+		switch data := upd.CallbackQuery.Data; data {
+		case "start", "draw", "mypack":
+			return "cb:" + data
+		default:
+			if prefix := callbackPrefix(data); prefix != "" {
+				return "cb:" + strings.TrimSuffix(prefix, "_")
+			}
+			return "callback"
+		}
+	}
+	return "other"
+}
+
+func (h *Handler) sendStartMessage(ctx context.Context, chatID int64, from *tgbotapi.User) {
 	dbctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
 	defer cancel()
-	if err := h.service.Repo.UpsertBotUser(dbctx, chatID); err != nil {
+	var username, lang string
+	if from != nil {
+		username, lang = from.UserName, from.LanguageCode
+	}
+	if err := h.service.Repo.UpsertBotUser(dbctx, chatID, username, lang); err != nil {
 		log.Println("UpsertBotUser:", err)
 	}
+	camp, _, err := h.service.ResolveCampaign(dbctx)
+	if err != nil && !errors.Is(err, repositories.ErrNoCampaign) {
+		log.Println("ResolveCampaign:", err)
+	}
 
 	mk := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Получить стикерпак", "draw"),
 		))
 
-	caption := "🎯<b><u>Готов испытать свою удачу?</u></b>\n" +
-		"Запускай Колесо Фортуны и забирай один из <i>фирменных ультра-брутальных</i> стикерпаков <b>TWILIGHT HAMMER!</b>\n" +
-		"☸️<i>Крути колесо, боец! Забери свой трофей!</i>"
-
 	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "start.jpg", Bytes: StartJPG})
-	photo.Caption = caption
+	photo.Caption = orDefault(camp.StartText, defaultStartText)
 	photo.ReplyMarkup = mk
 	photo.ParseMode = tgbotapi.ModeHTML
-	if _, err := h.sender.Send(ctx, photo); err != nil {
-		log.Println("sendStartMessage:", err)
-	}
+	h.send(ctx, photo)
 }
 
 func (h *Handler) handleCallback(ctx context.Context, q *tgbotapi.CallbackQuery) {
@@ -116,46 +176,113 @@ func (h *Handler) handleCallback(ctx context.Context, q *tgbotapi.CallbackQuery)
 		return
 	}
 
+	// Всё, кроме пользовательских кнопок, — только для админов с нужной ролью
+	if need, ok := callbackRole(q.Data); ok {
+		role := h.service.AdminRole(ctx, q.From.ID)
+		if !services.Allows(role, need) {
+			if role != "" {
+				h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, noAccessText))
+			}
+			return
+		}
+	}
+
 	switch {
 	case q.Data == "start":
-		h.sendStartMessage(ctx, q.Message.Chat.ID)
+		h.sendStartMessage(ctx, q.Message.Chat.ID, q.From)
 
 	case q.Data == "draw":
 		h.processDraw(ctx, q.Message.Chat.ID, q.From.ID)
 
+	case q.Data == "mypack":
+		h.sendMyPack(ctx, q.Message.Chat.ID, q.From.ID)
+
 	case strings.HasPrefix(q.Data, "pack_"):
 		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "pack_"))
 		mk := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("✏️ Редактировать", fmt.Sprintf("edit_%d", id)),
 				tgbotapi.NewInlineKeyboardButtonData("🗑️ Удалить", fmt.Sprintf("del_%d", id)),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("⚖️ Вес", fmt.Sprintf("weight_%d", id)),
+				tgbotapi.NewInlineKeyboardButtonData("📦 Остаток", fmt.Sprintf("stock_%d", id)),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🎟 Загрузить коды", fmt.Sprintf("codes_%d", id)),
 			))
 		msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Что сделать со стикерпаком?")
 		msg.ReplyMarkup = mk
-		if _, err := h.sender.Send(ctx, msg); err != nil {
-			log.Println(err)
-		}
+		h.send(ctx, msg)
 
 	case strings.HasPrefix(q.Data, "del_"):
 		id := strings.TrimPrefix(q.Data, "del_")
 		mk := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("✅ Да, удалить", "delok_"+id),
+				tgbotapi.NewInlineKeyboardButtonData("✅ Да, в корзину", "delok_"+id),
 			))
-		msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Точно удалить?")
+		msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Переместить в корзину? Пак пропадёт из розыгрыша, выданные призы сохранятся.")
 		msg.ReplyMarkup = mk
-		if _, err := h.sender.Send(ctx, msg); err != nil {
-			log.Println(err)
-		}
+		h.send(ctx, msg)
 
 	case strings.HasPrefix(q.Data, "delok_"):
 		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "delok_"))
 		dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
 		defer cancel()
 		if err := h.service.Repo.DeleteStickerPack(dbctx, id); err != nil {
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Ошибка удаления: "+err.Error()))
+			h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Ошибка удаления: "+err.Error()))
 		} else {
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "✅ Удалено"))
+			h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "✅ Перемещено в корзину"))
+		}
+
+	case strings.HasPrefix(q.Data, "trash_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "trash_"))
+		h.showTrash(ctx, q.Message.Chat.ID, id)
+
+	case strings.HasPrefix(q.Data, "trashpack_"):
+		id := strings.TrimPrefix(q.Data, "trashpack_")
+		mk := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("♻️ Восстановить", "restore_"+id),
+				tgbotapi.NewInlineKeyboardButtonData("❌ Удалить навсегда", "purge_"+id),
+			))
+		msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Что сделать со стикерпаком из корзины?")
+		msg.ReplyMarkup = mk
+		h.send(ctx, msg)
+
+	case strings.HasPrefix(q.Data, "restore_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "restore_"))
+		dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer cancel()
+		err := h.service.Repo.RestoreStickerPack(dbctx, id)
+		switch {
+		case errors.Is(err, repositories.ErrPackNameTaken):
+			h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID,
+				"В кампании уже есть стикерпак с таким названием — переименуйте его и повторите."))
+		case err != nil:
+			h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Ошибка: "+err.Error()))
+		default:
+			h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "♻️ Восстановлено"))
+		}
+
+	case strings.HasPrefix(q.Data, "purge_"):
+		id := strings.TrimPrefix(q.Data, "purge_")
+		mk := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✅ Да, удалить навсегда", "purgeok_"+id),
+			))
+		msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Удалить навсегда? Коды пака пропадут, в истории выигрышей останется пустое место.")
+		msg.ReplyMarkup = mk
+		h.send(ctx, msg)
+
+	case strings.HasPrefix(q.Data, "purgeok_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "purgeok_"))
+		dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		if err := h.service.Repo.PurgeStickerPack(dbctx, id); err != nil {
+			h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Ошибка удаления: "+err.Error()))
+		} else {
+			h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "✅ Удалено навсегда"))
 		}
 
 	case strings.HasPrefix(q.Data, "edit_"):
@@ -165,77 +292,327 @@ func (h *Handler) handleCallback(ctx context.Context, q *tgbotapi.CallbackQuery)
 		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
 			UserID: q.From.ID, State: "edit_wait_name", Data: id,
 		})
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Отправьте новое название:"))
+		h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Отправьте новое название:"))
+
+	case strings.HasPrefix(q.Data, "weight_"):
+		id := strings.TrimPrefix(q.Data, "weight_")
+		dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer cancel()
+		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+			UserID: q.From.ID, State: "weight_wait_value", Data: id,
+		})
+		h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, weightPrompt))
+
+	case strings.HasPrefix(q.Data, "stock_"):
+		id := strings.TrimPrefix(q.Data, "stock_")
+		dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer cancel()
+		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+			UserID: q.From.ID, State: "stock_wait_value", Data: id,
+		})
+		h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, stockPrompt))
+
+	case strings.HasPrefix(q.Data, "codes_"):
+		id := strings.TrimPrefix(q.Data, "codes_")
+		dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer cancel()
+		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+			UserID: q.From.ID, State: "codes_wait_file", Data: id,
+		})
+		h.send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, codesPrompt))
+
+	case strings.HasPrefix(q.Data, "cmp"):
+		h.handleCampaignCallback(ctx, q)
+
+	case strings.HasPrefix(q.Data, "adm"):
+		h.handleAdminsCallback(ctx, q)
+
+	case strings.HasPrefix(q.Data, "audit_"):
+		// audit_o<id> — старше записи, audit_n<id> — новее; прочее — первая страница
+		var older, newer int64
+		cursor := strings.TrimPrefix(q.Data, "audit_")
+		if id, err := strconv.ParseInt(cursor[min(len(cursor), 1):], 10, 64); err == nil {
+			switch cursor[0] {
+			case 'o':
+				older = id
+			case 'n':
+				newer = id
+			}
+		}
+		h.showAudit(ctx, q.Message.Chat.ID, older, newer)
+
+	case strings.HasPrefix(q.Data, "bc"):
+		h.handleBroadcastCallback(ctx, q)
+
+	case strings.HasPrefix(q.Data, "seg"):
+		h.handleSegmentCallback(ctx, q)
+
+	case strings.HasPrefix(q.Data, "imp_"):
+		h.handleImportCallback(ctx, q)
+
+	case strings.HasPrefix(q.Data, "exp_"):
+		h.sendExport(ctx, q.Message.Chat.ID, strings.TrimPrefix(q.Data, "exp_"))
 	}
 }
 
-func (h *Handler) handleAdminCommand(ctx context.Context, m *tgbotapi.Message) {
+const noAccessText = "⛔ Недостаточно прав"
+
+func (h *Handler) handleAdminCommand(ctx context.Context, m *tgbotapi.Message, role models.AdminRole) {
+	if need, ok := commandRole(m.Command()); ok && !services.Allows(role, need) {
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, noAccessText))
+		return
+	}
+
 	switch m.Command() {
 	case "start":
-		h.sendStartMessage(ctx, m.Chat.ID)
+		h.sendStartMessage(ctx, m.Chat.ID, m.From)
 	case "packs":
-		h.showPacksList(ctx, m.Chat.ID)
+		if camp, ok := h.currentCampaignForAdmin(ctx, m.Chat.ID); ok {
+			h.showPacksList(ctx, m.Chat.ID, camp.ID)
+		}
 	case "addpack":
-		dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
-		defer cancel()
-		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
-			UserID: m.From.ID, State: "add_wait_name",
-		})
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Отправьте название нового стикерпака:"))
+		if camp, ok := h.currentCampaignForAdmin(ctx, m.Chat.ID); ok {
+			h.startAddPack(ctx, m.Chat.ID, m.From.ID, camp.ID)
+		}
+	case "importpacks":
+		if camp, ok := h.currentCampaignForAdmin(ctx, m.Chat.ID); ok {
+			h.startImportPacks(ctx, m.Chat.ID, m.From.ID, camp.ID)
+		}
+	case "campaigns":
+		h.showCampaignsList(ctx, m.Chat.ID)
+	case "stats":
+		h.showCurrentStats(ctx, m.Chat.ID)
+	case "export":
+		h.showExportMenu(ctx, m.Chat.ID)
+	case "admins":
+		h.showAdminsList(ctx, m.Chat.ID)
+	case "audit":
+		h.showAudit(ctx, m.Chat.ID, 0, 0)
+	case "broadcast":
+		h.startBroadcast(ctx, m.Chat.ID, m.From.ID)
+	case "segments":
+		h.showSegmentsList(ctx, m.Chat.ID)
+	case "addsegment":
+		h.startAddSegment(ctx, m.Chat.ID, m.From.ID)
 	case "draw":
 		h.processDraw(ctx, m.Chat.ID, m.From.ID)
+	case "mypack":
+		h.sendMyPack(ctx, m.Chat.ID, m.From.ID)
 	}
 }
 
-func (h *Handler) showPacksList(ctx context.Context, chatID int64) {
+func (h *Handler) startAddPack(ctx context.Context, chatID, userID int64, campaignID int) {
 	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
 	defer cancel()
-	packs, err := h.service.Repo.GetStickerPacks(dbctx)
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: userID, State: "add_wait_name", Data: strconv.Itoa(campaignID),
+	})
+	h.send(ctx, tgbotapi.NewMessage(chatID, "Отправьте название нового стикерпака:"))
+}
+
+func (h *Handler) showPacksList(ctx context.Context, chatID int64, campaignID int) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	packs, err := h.service.Repo.GetStickerPacks(dbctx, campaignID)
 	if err != nil {
 		log.Println("GetStickerPacks:", err)
 		return
 	}
-	if len(packs) == 0 {
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(chatID, "Стикерпаков не добавлено"))
+	trash, err := h.service.Repo.GetDeletedStickerPacks(dbctx, campaignID)
+	if err != nil {
+		log.Println("GetDeletedStickerPacks:", err)
+		return
+	}
+	if len(packs) == 0 && len(trash) == 0 {
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Стикерпаков не добавлено"))
 		return
 	}
 	var rows [][]tgbotapi.InlineKeyboardButton
 	for _, p := range packs {
-		btn := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("[%d] %s", p.ID, p.Name), fmt.Sprintf("pack_%d", p.ID))
+		btn := tgbotapi.NewInlineKeyboardButtonData(packLabel(p), fmt.Sprintf("pack_%d", p.ID))
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(btn))
 	}
+	if len(trash) > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("🗑️ Корзина (%d)", len(trash)), fmt.Sprintf("trash_%d", campaignID))))
+	}
+	text := "Выберите стикерпак:"
+	if len(packs) == 0 {
+		text = "Стикерпаков нет, но есть удалённые:"
+	}
 	mk := tgbotapi.NewInlineKeyboardMarkup(rows...)
-	msg := tgbotapi.NewMessage(chatID, "Выберите стикерпак:")
+	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = mk
-	_, _ = h.sender.Send(ctx, msg)
+	h.send(ctx, msg)
+}
+
+func (h *Handler) showTrash(ctx context.Context, chatID int64, campaignID int) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	packs, err := h.service.Repo.GetDeletedStickerPacks(dbctx, campaignID)
+	if err != nil {
+		log.Println("GetDeletedStickerPacks:", err)
+		return
+	}
+	if len(packs) == 0 {
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Корзина пуста"))
+		return
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, p := range packs {
+		btn := tgbotapi.NewInlineKeyboardButtonData(packLabel(p), fmt.Sprintf("trashpack_%d", p.ID))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(btn))
+	}
+	msg := tgbotapi.NewMessage(chatID, "Корзина — выберите стикерпак, чтобы восстановить или удалить навсегда:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.send(ctx, msg)
 }
 
-func (h *Handler) handleAdminDialog(ctx context.Context, m *tgbotapi.Message) {
+func (h *Handler) handleAdminDialog(ctx context.Context, m *tgbotapi.Message, role models.AdminRole) {
+	// Наблюдателю нечего вводить: все диалоги меняют данные
+	if !services.Allows(role, models.RoleEditor) {
+		return
+	}
 	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
 	defer cancel()
 	st, _ := h.service.Repo.GetAdminState(dbctx, m.From.ID)
 
+	if strings.HasPrefix(st.State, "adm_") {
+		if services.Allows(role, models.RoleOwner) {
+			h.handleAdminsDialog(ctx, m)
+		}
+		return
+	}
+	if strings.HasPrefix(st.State, "cmp_") {
+		h.handleCampaignDialog(ctx, m, st)
+		return
+	}
+	if strings.HasPrefix(st.State, "imp_") {
+		h.handleImportDialog(ctx, m, st)
+		return
+	}
+	if strings.HasPrefix(st.State, "seg_") {
+		h.handleSegmentDialog(ctx, m, st)
+		return
+	}
+	if st.State == "bc_wait_message" {
+		h.handleBroadcastMessage(ctx, m)
+		return
+	}
+
 	switch st.State {
 
 	case "add_wait_name":
 		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
-			UserID: m.From.ID, State: "add_wait_url", Data: m.Text,
+			UserID: m.From.ID, State: "add_wait_url", Data: st.Data + "|" + m.Text,
 		})
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте ссылку:"))
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте ссылку:"))
 
 	case "add_wait_url":
-		if err := h.service.Repo.CreateStickerPack(dbctx, st.Data, m.Text); err != nil {
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+		// Пак создаём только после ввода веса: брошенный диалог не оставит в розыгрыше
+		// полунастроенный приз. Data — «campID|JSON» с названием и ссылкой.
+		parts := strings.SplitN(st.Data, "|", 2)
+		if len(parts) < 2 {
+			// состояние из старого формата диалога
+			_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Диалог устарел, начните добавление заново."))
+			return
+		}
+		draft, err := json.Marshal(models.PackImport{Name: parts[1], URL: m.Text})
+		if err != nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+			return
+		}
+		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+			UserID: m.From.ID, State: "add_wait_weight", Data: parts[0] + "|" + string(draft),
+		})
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, weightPrompt))
+
+	case "add_wait_weight":
+		weight, err := parseWeight(m.Text)
+		if err != nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Вес должен быть целым числом ≥ 0. "+weightPrompt))
+			return
+		}
+		parts := strings.SplitN(st.Data, "|", 2)
+		campaignID, _ := strconv.Atoi(parts[0])
+		var draft models.PackImport
+		if len(parts) < 2 || json.Unmarshal([]byte(parts[1]), &draft) != nil {
+			_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Диалог устарел, начните добавление заново."))
+			return
+		}
+		if _, err := h.service.Repo.CreateStickerPack(dbctx, campaignID, draft.Name, draft.URL, weight); err != nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
 			return
 		}
 		_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Стикерпак добавлен"))
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Стикерпак добавлен"))
+
+	case "weight_wait_value":
+		weight, err := parseWeight(m.Text)
+		if err != nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Вес должен быть целым числом ≥ 0. "+weightPrompt))
+			return
+		}
+		id, _ := strconv.Atoi(st.Data)
+		if err := h.service.Repo.UpdateStickerPackWeight(dbctx, id, weight); err != nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+			return
+		}
+		_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Вес обновлён"))
+
+	case "stock_wait_value":
+		stock, err := parseStock(m.Text)
+		if err != nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Остаток должен быть целым числом ≥ 0 или «-». "+stockPrompt))
+			return
+		}
+		id, _ := strconv.Atoi(st.Data)
+		if err := h.service.Repo.UpdateStickerPackStock(dbctx, id, stock); err != nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+			return
+		}
+		_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Остаток обновлён"))
+
+	case "codes_wait_file":
+		if m.Document == nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, codesPrompt))
+			return
+		}
+		data, err := h.downloadDocument(ctx, m.Document)
+		if err != nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Не удалось скачать файл: "+err.Error()))
+			return
+		}
+		codes, err := services.ParseCodes(m.Document.FileName, data)
+		if err != nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка разбора файла: "+err.Error()))
+			return
+		}
+		id, _ := strconv.Atoi(st.Data)
+		// свежий контекст: скачивание могло съесть бюджет dbctx
+		codesCtx, codesCancel := context.WithTimeout(ctx, 2*time.Second)
+		defer codesCancel()
+		added, err := h.service.Repo.AddPackCodes(codesCtx, id, codes)
+		if err != nil {
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+			return
+		}
+		free, _ := h.service.Repo.CountFreeCodes(codesCtx, id)
+		_ = h.service.Repo.ClearAdminState(codesCtx, m.From.ID)
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf(
+			"✅ Загружено кодов: %d (пропущено дубликатов: %d). Свободно: %d.\n"+
+				"Каждый победитель этого пака получит свой код.",
+			added, len(codes)-added, free)))
 
 	case "edit_wait_name":
 		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
 			UserID: m.From.ID, State: "edit_wait_url", Data: st.Data + "|" + m.Text,
 		})
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте новую ссылку:"))
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте новую ссылку:"))
 
 	case "edit_wait_url":
 		parts := strings.SplitN(st.Data, "|", 2)
@@ -243,16 +620,102 @@ func (h *Handler) handleAdminDialog(ctx context.Context, m *tgbotapi.Message) {
 		newName := parts[1]
 		newURL := m.Text
 		if err := h.service.Repo.UpdateStickerPack(dbctx, id, newName, newURL); err != nil {
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+			h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
 			return
 		}
 		_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Обновлено"))
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Обновлено"))
+	}
+}
+
+const weightPrompt = "Отправьте вес выпадения (целое число ≥ 0).\n" +
+	"1 — обычный шанс, 5 — в пять раз чаще, 0 — пак не выпадает."
+
+func parseWeight(text string) (int, error) {
+	w, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		return 0, err
+	}
+	if w < 0 {
+		return 0, errors.New("negative weight")
+	}
+	return w, nil
+}
+
+const stockPrompt = "Отправьте остаток (целое число ≥ 0) или «-», чтобы снять ограничение."
+
+// nil — без ограничения
+func parseStock(text string) (*int, error) {
+	text = strings.TrimSpace(text)
+	if text == "-" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, errors.New("negative stock")
 	}
+	return &n, nil
 }
 
-func (h *Handler) subscribed(ctx context.Context, userID int64) bool {
-	if h.subChannelID == 0 {
+const codesPrompt = "Отправьте файл .txt или .csv с кодами: по одному в строке (в CSV — первая колонка)."
+
+// Максимальный размер загружаемого админом файла
+const maxDocumentSize = 5 << 20
+
+func (h *Handler) downloadDocument(ctx context.Context, doc *tgbotapi.Document) ([]byte, error) {
+	if doc.FileSize > maxDocumentSize {
+		return nil, fmt.Errorf("файл больше %d МБ", maxDocumentSize>>20)
+	}
+	if err := h.sender.Wait(ctx); err != nil {
+		return nil, err
+	}
+	link, err := h.bot.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		return nil, err
+	}
+
+	dlctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(dlctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+}
+
+func packLabel(p models.StickerPack) string {
+	label := fmt.Sprintf("[%d] %s (вес %d", p.ID, p.Name, p.Weight)
+	if p.Stock != nil {
+		label += fmt.Sprintf(", ост. %d", *p.Stock)
+	}
+	if p.Kind == models.PackKindCodes {
+		label += fmt.Sprintf(", кодов %d", p.FreeCodes)
+	}
+	return label + ")"
+}
+
+// Текст с выигрышем: общая ссылка или персональный код
+func prizeText(c models.Campaign, p models.StickerPack) string {
+	text := orDefault(c.WinText, defaultWinText) + "\n\n"
+	if p.Kind == models.PackKindCodes {
+		text += "Твой код: <code>" + html.EscapeString(p.Code) + "</code>\n"
+	}
+	return text + p.URL
+}
+
+func (h *Handler) subscribed(ctx context.Context, channelID, userID int64) bool {
+	if channelID == 0 {
 		return true
 	}
 	// Учитываем общий лимит Telegram
@@ -261,7 +724,7 @@ func (h *Handler) subscribed(ctx context.Context, userID int64) bool {
 		return false
 	}
 
-	cfg := tgbotapi.ChatConfigWithUser{ChatID: h.subChannelID, UserID: userID}
+	cfg := tgbotapi.ChatConfigWithUser{ChatID: channelID, UserID: userID}
 	member, err := h.bot.GetChatMember(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: cfg})
 	if err != nil {
 		log.Println("GetChatMember:", err)
@@ -276,81 +739,199 @@ func (h *Handler) subscribed(ctx context.Context, userID int64) bool {
 }
 
 func (h *Handler) processDraw(ctx context.Context, chatID, userID int64) {
+	campCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	camp, status, err := h.service.ResolveCampaign(campCtx)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNoCampaign) {
+			log.Println("ResolveCampaign:", err)
+			metrics.DrawOutcomes.WithLabelValues(metrics.DrawError, "").Inc()
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
+			return
+		}
+		metrics.DrawOutcomes.WithLabelValues(metrics.DrawOutsideWindow, "").Inc()
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Сейчас розыгрыш не проводится. Следи за новостями!"))
+		return
+	}
+	// Вне окна кампании не крутим, а говорим, когда приходить
+	switch status {
+	case services.CampaignScheduled:
+		metrics.DrawOutcomes.WithLabelValues(metrics.DrawOutsideWindow, "").Inc()
+		h.send(ctx, tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"⏳ Розыгрыш «%s» начнётся через %s (%s).",
+			camp.Name, formatCountdown(time.Until(*camp.StartsAt)), formatCampaignTime(camp.StartsAt, ""))))
+		return
+	case services.CampaignEnded:
+		metrics.DrawOutcomes.WithLabelValues(metrics.DrawOutsideWindow, "").Inc()
+		h.send(ctx, tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"🏁 Розыгрыш «%s» завершён. Следи за новостями — скоро будет новый!", camp.Name)))
+		return
+	}
+
 	// Проверка подписки
+	channelID, channelLink := h.subChannel(camp)
 	subCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
-	if !h.subscribed(subCtx, userID) {
+	if !h.subscribed(subCtx, channelID, userID) {
+		metrics.DrawOutcomes.WithLabelValues(metrics.DrawNotSubscribed, "").Inc()
 		mk := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("Проверить подписку", "draw"),
 			))
-		msg := tgbotapi.NewMessage(chatID, "Подпишись на канал "+h.subChannelLink+", чтобы получить стикерпак")
+		msg := tgbotapi.NewMessage(chatID, "Подпишись на канал "+channelLink+", чтобы получить стикерпак")
 		msg.ReplyMarkup = mk
-		_, _ = h.sender.Send(ctx, msg)
+		h.send(ctx, msg)
 		return
 	}
 
 	// Клейм + выбор пакета
 	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
 	defer cancel()
-	p, err := h.service.ClaimStickerPack(dbctx, userID, h.adminID)
+	// Редакторы и владельцы крутят без ограничения попыток (тестовые розыгрыши тратят
+	// остатки и коды). Наблюдатель только смотрит — для него действует одна попытка.
+	unlimited := services.Allows(h.service.AdminRole(ctx, userID), models.RoleEditor)
+	// Приз и допродажа уходят через outbox в транзакции клейма: рестарт во время
+	// кубика не оставит пользователя с потраченной попыткой и без приза
+	var winMsgs []int64
+	p, err := h.service.ClaimStickerPack(dbctx, camp.ID, userID, unlimited,
+		func(tx *repositories.Repository, p models.StickerPack) error {
+			ids, err := h.enqueueWinMessages(dbctx, tx, chatID, camp, p)
+			winMsgs = ids
+			return err
+		})
 	if err != nil {
 		switch {
 		case errors.Is(err, services.ErrAlreadyClaimed):
+			metrics.DrawOutcomes.WithLabelValues(metrics.DrawAlreadyClaimed, "").Inc()
 			mk := tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("🎁 Мой стикерпак", "mypack"),
+				),
 				tgbotapi.NewInlineKeyboardRow(
 					tgbotapi.NewInlineKeyboardButtonURL("Заказать броню", h.shopURL),
 				))
-			msg := tgbotapi.NewMessage(chatID,
-				"⚡️<u>Попытка была одна — и Фортуна уже выбрала стикерпак под твой стиль!</u>\n"+
-					"🔄Хочешь другой? Тогда заказывай нашу броню TWILIGHT HAMMER и получай в бонус фирменный стикерпак, который идёт в комплекте с экипировкой.\n\n"+
-					"<b>Заказать можешь тут:</b>\n"+
-					"🟣<b><a href=\"https://www.wildberries.ru/brands/311439225-twilight-hammer\">WILDBERRIES</a></b>\n"+
-					"🔵<b><a href=\"https://vk.com/t.hammer.clan\">VKONTAKTE</a></b>")
+			msg := tgbotapi.NewMessage(chatID, orDefault(camp.UpsellText, defaultUpsellText))
 			msg.ParseMode = tgbotapi.ModeHTML
 			msg.ReplyMarkup = mk
-			_, _ = h.sender.Send(ctx, msg)
+			h.send(ctx, msg)
 			return
 		case errors.Is(err, repositories.ErrNoPacks):
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(chatID, "⚠️ Стикерпаков пока нет. Попробуйте позже."))
+			metrics.DrawOutcomes.WithLabelValues(metrics.DrawNoPacks, "").Inc()
+			h.send(ctx, tgbotapi.NewMessage(chatID, "⚠️ Стикерпаков пока нет. Попробуйте позже."))
 			return
 		default:
 			log.Println("ClaimStickerPack:", err)
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
+			metrics.DrawOutcomes.WithLabelValues(metrics.DrawError, "").Inc()
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
 			return
 		}
 	}
 
-	// Отправляем "кубик" сразу…
+	metrics.DrawOutcomes.WithLabelValues(metrics.DrawWon, strconv.Itoa(p.ID)).Inc()
+	// тестовый розыгрыш ничего не списывает
+	if !unlimited && services.SoldOut(p) {
+		h.notifySoldOut(ctx, p)
+	}
+
+	// Сначала "кубик", потом приз: время приза и допродажи отсчитываем от момента,
+	// когда кубик ушёл (лимит чата в Sender мог его придержать)
 	dice := tgbotapi.NewDice(chatID)
 	dice.Emoji = "🎲"
-	_, _ = h.sender.Send(ctx, dice)
+	h.send(ctx, dice)
+	h.scheduleWinMessages(ctx, winMsgs)
+}
 
-	// …а дальше — без блокировки текущего воркера
-	go func(chatID int64, url, shop string) {
-		time.Sleep(2 * time.Second)
+// Приз уходит через 2 с после кубика, пока он крутится, допродажа — ещё через 1 с.
+// До отправки кубика они отложены на winHold: если бот упадёт между клеймом
+// и кубиком, outbox всё равно отправит приз.
+var winDelays = []time.Duration{2 * time.Second, 3 * time.Second}
 
-		text := "😎<b>НИШТЯК!</b> Ты залутал крутой стикерпак!\n" +
-			"⚔️Теперь у тебя в руках оружие для чатов — <i>бей словами, жги эмоциями, взрывай переписки!</i>\n\n" + url
-		msg := tgbotapi.NewMessage(chatID, text)
-		msg.ParseMode = tgbotapi.ModeHTML
-		_, _ = h.sender.Send(context.Background(), msg)
+const winHold = 30 * time.Second
 
-		time.Sleep(1 * time.Second)
+// enqueueWinMessages ставит в outbox сообщение о призе и допродажу, отложенные на winHold;
+// возвращает их id для scheduleWinMessages
+func (h *Handler) enqueueWinMessages(ctx context.Context, tx *repositories.Repository, chatID int64, camp models.Campaign, p models.StickerPack) ([]int64, error) {
+	msg := tgbotapi.NewMessage(chatID, prizeText(camp, p))
+	msg.ParseMode = tgbotapi.ModeHTML
 
-		mk := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonURL("Заказать броню", shop),
-			))
-		after := "⚡️<u>Попытка была одна — и Фортуна уже выбрала стикерпак под твой стиль!</u>\n" +
-			"🔄Хочешь другой? Тогда заказывай нашу броню TWILIGHT HAMMER и получай в бонус фирменный стикерпак, который идёт в комплекте с экипировкой.\n\n" +
-			"<b>Заказать можешь тут:</b>\n" +
-			"🟣<b><a href=\"https://www.wildberries.ru/brands/311439225-twilight-hammer\">WILDBERRIES</a></b>\n" +
-			"🔵<b><a href=\"https://vk.com/t.hammer.clan\">VKONTAKTE</a></b>"
-
-		am := tgbotapi.NewMessage(chatID, after)
-		am.ParseMode = tgbotapi.ModeHTML
-		am.ReplyMarkup = mk
-		_, _ = h.sender.Send(context.Background(), am)
-	}(chatID, p.URL, h.shopURL)
+	am := tgbotapi.NewMessage(chatID, orDefault(camp.UpsellText, defaultUpsellText))
+	am.ParseMode = tgbotapi.ModeHTML
+	am.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("Заказать броню", h.shopURL),
+		))
+
+	var ids []int64
+	for _, m := range []tgbotapi.MessageConfig{msg, am} {
+		om, err := services.NewOutboxMessage(m, winHold)
+		if err != nil {
+			return nil, err
+		}
+		id, err := tx.EnqueueOutbox(ctx, om)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// scheduleWinMessages — кубик отправлен: приз и допродажа уходят через winDelays
+func (h *Handler) scheduleWinMessages(ctx context.Context, ids []int64) {
+	// и когда обработка апдейта вышла за срок, ожидая кубик: иначе приз ждал бы winHold
+	dbctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 500*time.Millisecond)
+	defer cancel()
+	for i, id := range ids {
+		if err := h.service.Repo.ScheduleOutbox(dbctx, id, winDelays[i]); err != nil {
+			log.Println("ScheduleOutbox:", err)
+			return
+		}
+	}
+}
+
+// Повторно отправляет пользователю выигранный пак
+func (h *Handler) sendMyPack(ctx context.Context, chatID, userID int64) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	camp, err := h.service.PrizeCampaign(dbctx)
+	if err != nil && !errors.Is(err, repositories.ErrNoCampaign) {
+		log.Println("PrizeCampaign:", err)
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
+		return
+	}
+	// кампаний нет — camp.ID = 0, и клейм не найдётся
+	p, err := h.service.Repo.GetClaimedPack(dbctx, camp.ID, userID)
+	switch {
+	case errors.Is(err, repositories.ErrNoClaim):
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Ты ещё ничего не выиграл — жми /draw!"))
+		return
+	case errors.Is(err, repositories.ErrPrizeGone):
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Твой выигрыш был, но этот приз больше недоступен. Напиши администратору, если он тебе ещё нужен."))
+		return
+	case err != nil:
+		log.Println("GetClaimedPack:", err)
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, prizeText(camp, p))
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.send(ctx, msg)
+}
+
+// Сообщаем редакторам и владельцам, что пак с ограниченным остатком закончился
+func (h *Handler) notifySoldOut(ctx context.Context, p models.StickerPack) {
+	dbctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	admins, err := h.service.Repo.GetAdmins(dbctx)
+	if err != nil {
+		log.Println("GetAdmins:", err)
+		return
+	}
+	text := fmt.Sprintf("⚠️ Стикерпак [%d] %s закончился и больше не выпадает.", p.ID, p.Name)
+	for _, a := range admins {
+		if !services.Allows(a.Role, models.RoleEditor) {
+			continue
+		}
+		h.send(ctx, tgbotapi.NewMessage(a.UserID, text))
+	}
 }
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/services"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const importPrompt = "Отправьте файл .csv или .json с призами.\n" +
+	"CSV: колонки name, url, weight, stock (заголовок необязателен, вес по умолчанию 1, пустой остаток — без ограничения).\n" +
+	"JSON: [{\"name\": \"…\", \"url\": \"https://t.me/addstickers/…\", \"weight\": 1, \"stock\": 100}]"
+
+// Сколько проблемных строк показывать в превью
+const maxImportProblems = 30
+
+func (h *Handler) startImportPacks(ctx context.Context, chatID, userID int64, campaignID int) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: userID, State: "imp_wait_file", Data: strconv.Itoa(campaignID),
+	})
+	h.send(ctx, tgbotapi.NewMessage(chatID, importPrompt))
+}
+
+// Файл получен: разбираем, проверяем и показываем превью. Годные строки
+// ждут подтверждения в admin_states («campaignID|json»).
+func (h *Handler) handleImportDialog(ctx context.Context, m *tgbotapi.Message, st models.AdminState) {
+	reply := func(text string) {
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, text))
+	}
+	if st.State != "imp_wait_file" {
+		reply("Подтвердите или отмените импорт кнопками выше.")
+		return
+	}
+	if m.Document == nil {
+		reply(importPrompt)
+		return
+	}
+	data, err := h.downloadDocument(ctx, m.Document)
+	if err != nil {
+		reply("Не удалось скачать файл: " + err.Error())
+		return
+	}
+	rows, err := services.ParsePackImport(data)
+	if err != nil {
+		reply("Ошибка разбора файла: " + err.Error())
+		return
+	}
+
+	campaignID, _ := strconv.Atoi(st.Data)
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	packs, err := h.service.Repo.GetStickerPacks(dbctx, campaignID)
+	if err != nil {
+		reply("Ошибка: " + err.Error())
+		return
+	}
+	existing := make([]string, 0, len(packs))
+	for _, p := range packs {
+		existing = append(existing, p.Name)
+	}
+	services.ValidatePackImport(rows, existing)
+
+	var (
+		valid    []models.PackImport
+		problems []string
+	)
+	for _, row := range rows {
+		if row.Problem != "" {
+			problems = append(problems, fmt.Sprintf("строка %d: %s", row.Line, row.Problem))
+			continue
+		}
+		valid = append(valid, row.Pack)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Строк в файле: %d, к импорту: %d, с ошибками: %d.\n", len(rows), len(valid), len(problems))
+	for i, p := range problems {
+		if i == maxImportProblems {
+			fmt.Fprintf(&b, "…и ещё %d\n", len(problems)-i)
+			break
+		}
+		b.WriteString("• " + p + "\n")
+	}
+	if len(valid) == 0 {
+		b.WriteString("\nИмпортировать нечего — исправьте файл и отправьте снова.")
+		reply(b.String())
+		return
+	}
+
+	payload, _ := json.Marshal(valid)
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: m.From.ID, State: "imp_confirm", Data: st.Data + "|" + string(payload),
+	})
+	if len(problems) > 0 {
+		b.WriteString("\nСтроки с ошибками будут пропущены.")
+	}
+	msg := tgbotapi.NewMessage(m.Chat.ID, b.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ Импортировать %d", len(valid)), "imp_ok"),
+		tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "imp_cancel"),
+	))
+	h.send(ctx, msg)
+}
+
+func (h *Handler) handleImportCallback(ctx context.Context, q *tgbotapi.CallbackQuery) {
+	chatID := q.Message.Chat.ID
+	dbctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	reply := func(text string) {
+		h.send(ctx, tgbotapi.NewMessage(chatID, text))
+	}
+
+	st, _ := h.service.Repo.GetAdminState(dbctx, q.From.ID)
+	if st.State != "imp_confirm" {
+		reply("Нет импорта, ожидающего подтверждения.")
+		return
+	}
+	if q.Data == "imp_cancel" {
+		_ = h.service.Repo.ClearAdminState(dbctx, q.From.ID)
+		reply("Импорт отменён")
+		return
+	}
+
+	idStr, payload, _ := strings.Cut(st.Data, "|")
+	campaignID, _ := strconv.Atoi(idStr)
+	var packs []models.PackImport
+	if err := json.Unmarshal([]byte(payload), &packs); err != nil {
+		reply("Ошибка: " + err.Error())
+		return
+	}
+	if err := h.service.Repo.ImportStickerPacks(dbctx, campaignID, packs); err != nil {
+		log.Println("ImportStickerPacks:", err)
+		reply("Импорт не выполнен, ничего не добавлено: " + err.Error())
+		return
+	}
+	_ = h.service.Repo.ClearAdminState(dbctx, q.From.ID)
+	reply(fmt.Sprintf("✅ Импортировано призов: %d", len(packs)))
+	h.showPacksList(ctx, chatID, campaignID)
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Формат дат в условии joined
+const segmentDateLayout = "02.01.2006"
+
+const segmentPrompt = "Отправьте условия сегмента, по одному на строку (все должны выполняться):\n" +
+	"never_claimed — ещё ничего не выиграли\n" +
+	"pack=ID — выиграли стикерпак с этим ID\n" +
+	"joined=ДД.ММ.ГГГГ..ДД.ММ.ГГГГ — пришли в бота в эти дни (любую границу можно опустить)\n" +
+	"lang=ru — язык Telegram\n" +
+	"«-» — все пользователи."
+
+// parseSegmentFilter разбирает условия сегмента из текста админа
+func parseSegmentFilter(text string) (models.SegmentFilter, error) {
+	var f models.SegmentFilter
+	if strings.TrimSpace(text) == "-" {
+		return f, nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(line, "=")
+		key, val = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(val)
+		switch key {
+		case "never_claimed":
+			f.NeverClaimed = true
+		case "pack":
+			id, err := strconv.Atoi(val)
+			if err != nil || id <= 0 {
+				return f, fmt.Errorf("pack: нужен ID стикерпака")
+			}
+			f.ClaimedPackID = id
+		case "joined":
+			fromStr, toStr, ok := strings.Cut(val, "..")
+			if !ok {
+				return f, errors.New("joined: нужен диапазон «С..ПО»")
+			}
+			if fromStr = strings.TrimSpace(fromStr); fromStr != "" {
+				from, err := time.ParseInLocation(segmentDateLayout, fromStr, time.Local)
+				if err != nil {
+					return f, fmt.Errorf("joined: %w", err)
+				}
+				f.JoinedFrom = &from
+			}
+			if toStr = strings.TrimSpace(toStr); toStr != "" {
+				to, err := time.ParseInLocation(segmentDateLayout, toStr, time.Local)
+				if err != nil {
+					return f, fmt.Errorf("joined: %w", err)
+				}
+				to = to.AddDate(0, 0, 1) // включительно по последний день
+				f.JoinedTo = &to
+			}
+		case "lang":
+			if val == "" {
+				return f, errors.New("lang: укажите код языка")
+			}
+			f.Language = strings.ToLower(val)
+		default:
+			return f, fmt.Errorf("неизвестное условие %q", key)
+		}
+	}
+	if f.NeverClaimed && f.ClaimedPackID != 0 {
+		return f, errors.New("never_claimed и pack взаимоисключают друг друга")
+	}
+	return f, nil
+}
+
+// describeSegment — человекочитаемое описание условий
+func describeSegment(f models.SegmentFilter) string {
+	var parts []string
+	if f.NeverClaimed {
+		parts = append(parts, "ещё ничего не выиграли")
+	}
+	if f.ClaimedPackID != 0 {
+		parts = append(parts, fmt.Sprintf("выиграли пак #%d", f.ClaimedPackID))
+	}
+	if f.JoinedFrom != nil {
+		parts = append(parts, "пришли с "+f.JoinedFrom.In(time.Local).Format(segmentDateLayout))
+	}
+	if f.JoinedTo != nil {
+		parts = append(parts, "пришли по "+f.JoinedTo.In(time.Local).AddDate(0, 0, -1).Format(segmentDateLayout))
+	}
+	if f.Language != "" {
+		parts = append(parts, "язык "+f.Language)
+	}
+	if len(parts) == 0 {
+		return "все пользователи"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *Handler) showSegmentsList(ctx context.Context, chatID int64) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	list, err := h.service.Repo.GetSegments(dbctx)
+	if err != nil {
+		log.Println("GetSegments:", err)
+		return
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, s := range list {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(s.Name, fmt.Sprintf("seg_%d", s.ID))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Новый сегмент", "segnew")))
+
+	text := "Сегменты аудитории:"
+	if len(list) == 0 {
+		text = "Сегментов пока нет"
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.send(ctx, msg)
+}
+
+func (h *Handler) startAddSegment(ctx context.Context, chatID, userID int64) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{UserID: userID, State: "seg_wait_filter"})
+	h.send(ctx, tgbotapi.NewMessage(chatID, segmentPrompt))
+}
+
+func (h *Handler) handleSegmentCallback(ctx context.Context, q *tgbotapi.CallbackQuery) {
+	chatID := q.Message.Chat.ID
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	switch {
+	case q.Data == "segnew":
+		h.startAddSegment(ctx, chatID, q.From.ID)
+
+	case strings.HasPrefix(q.Data, "segdel_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "segdel_"))
+		if err := h.service.Repo.DeleteSegment(dbctx, id); err != nil {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Ошибка: "+err.Error()))
+			return
+		}
+		h.send(ctx, tgbotapi.NewMessage(chatID, "🗑️ Сегмент удалён"))
+
+	case strings.HasPrefix(q.Data, "seg_"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "seg_"))
+		seg, err := h.service.Repo.GetSegment(dbctx, id)
+		if errors.Is(err, repositories.ErrNoSegment) {
+			h.send(ctx, tgbotapi.NewMessage(chatID, "Сегмент не найден"))
+			return
+		}
+		if err != nil {
+			log.Println("GetSegment:", err)
+			return
+		}
+		total, err := h.service.Repo.CountSegment(dbctx, seg.Filter)
+		if err != nil {
+			log.Println("CountSegment:", err)
+		}
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Сегмент «%s»\nУсловия: %s\nПолучателей сейчас: %d",
+			seg.Name, describeSegment(seg.Filter), total))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑️ Удалить", fmt.Sprintf("segdel_%d", id)),
+		))
+		h.send(ctx, msg)
+	}
+}
+
+func (h *Handler) handleSegmentDialog(ctx context.Context, m *tgbotapi.Message, st models.AdminState) {
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	reply := func(text string) {
+		h.send(ctx, tgbotapi.NewMessage(m.Chat.ID, text))
+	}
+
+	switch st.State {
+	case "seg_wait_filter":
+		f, err := parseSegmentFilter(m.Text)
+		if err != nil {
+			reply("Не понял условия: " + err.Error() + "\n\n" + segmentPrompt)
+			return
+		}
+		total, err := h.service.Repo.CountSegment(dbctx, f)
+		if err != nil {
+			reply("Ошибка: " + err.Error())
+			return
+		}
+		data, _ := json.Marshal(f)
+		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{UserID: m.From.ID, State: "seg_wait_name", Data: string(data)})
+		reply(fmt.Sprintf("Условия: %s\nПолучателей сейчас: %d\n\nОтправьте название сегмента:", describeSegment(f), total))
+
+	case "seg_wait_name":
+		var f models.SegmentFilter
+		if err := json.Unmarshal([]byte(st.Data), &f); err != nil {
+			reply("Ошибка: " + err.Error())
+			return
+		}
+		if _, err := h.service.Repo.CreateSegment(dbctx, strings.TrimSpace(m.Text), f, m.From.ID); err != nil {
+			reply("Ошибка: " + err.Error())
+			return
+		}
+		_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+		reply("✅ Сегмент сохранён")
+		h.showSegmentsList(ctx, m.Chat.ID)
+	}
+}
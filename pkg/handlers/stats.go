@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// statsPeriods — начало сегодняшнего дня и текущей недели (с понедельника) в поясе бота
+func statsPeriods(now time.Time) (dayStart, weekStart time.Time) {
+	now = now.In(time.Local)
+	dayStart = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	weekday := (int(now.Weekday()) + 6) % 7 // понедельник — 0
+	weekStart = dayStart.AddDate(0, 0, -weekday)
+	return dayStart, weekStart
+}
+
+// conversion — доля пришедших в бота за время кампании, получивших приз, в процентах
+func conversion(claimants, users int) float64 {
+	if users == 0 {
+		return 0
+	}
+	return float64(claimants) * 100 / float64(users)
+}
+
+// /stats без аргументов: кампания, которую сейчас видят пользователи
+func (h *Handler) showCurrentStats(ctx context.Context, chatID int64) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	camp, _, err := h.service.ResolveCampaign(dbctx)
+	if errors.Is(err, repositories.ErrNoCampaign) {
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Кампаний пока нет"))
+		return
+	}
+	if err != nil {
+		log.Println("ResolveCampaign:", err)
+		return
+	}
+	h.showStats(ctx, chatID, camp)
+}
+
+func (h *Handler) showStats(ctx context.Context, chatID int64, camp models.Campaign) {
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	dayStart, weekStart := statsPeriods(time.Now())
+	st, err := h.service.Repo.GetCampaignStats(dbctx, camp.ID, dayStart, weekStart)
+	if err != nil {
+		log.Println("GetCampaignStats:", err)
+		h.send(ctx, tgbotapi.NewMessage(chatID, "Ошибка: "+err.Error()))
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 <b>%s</b>\n\n", html.EscapeString(camp.Name))
+	fmt.Fprintf(&b, "<b>Сегодня:</b> новых пользователей %d, призов %d\n", st.Today.Users, st.Today.Claims)
+	fmt.Fprintf(&b, "<b>Неделя:</b> новых пользователей %d, призов %d\n", st.Week.Users, st.Week.Claims)
+	fmt.Fprintf(&b, "<b>Всё время:</b> пользователей %d, призов %d\n", st.All.Users, st.All.Claims)
+	fmt.Fprintf(&b, "<b>Победителей в кампании:</b> %d\n", st.Claimants)
+	fmt.Fprintf(&b, "<b>Конверсия старт → приз</b> (пришли за время кампании): %.1f%% (%d из %d)\n",
+		conversion(st.WindowClaimants, st.WindowUsers), st.WindowClaimants, st.WindowUsers)
+	if len(st.Packs) > 0 {
+		b.WriteString("\n<b>Призы:</b>\n")
+		for _, p := range st.Packs {
+			name := html.EscapeString(p.Name)
+			if p.Deleted {
+				name += " (в корзине)"
+			}
+			fmt.Fprintf(&b, "• %s — %d\n", name, p.Claims)
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.send(ctx, msg)
+}
@@ -0,0 +1,78 @@
+// Package metrics — метрики Prometheus бота; отдаются на /metrics
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "luckybot"
+
+var (
+	UpdatesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_received_total",
+		Help:      "Апдейты от Telegram по источнику (polling / webhook).",
+	}, []string{"source"})
+
+	UpdatesDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_dropped_total",
+		Help:      "Апдейты, отброшенные из-за переполненной очереди воркеров при недоступной БД.",
+	})
+
+	UpdatesSpilled = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_spilled_total",
+		Help:      "Апдейты, отложенные в очередь в БД, пока воркеры заняты.",
+	})
+
+	UpdatesDuplicate = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_duplicate_total",
+		Help:      "Повторно полученные апдейты (тот же update_id), пропущенные без обработки.",
+	})
+
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_duration_seconds",
+		Help:      "Время обработки апдейта по команде или коллбэку.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"route"})
+
+	SenderWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sender_wait_seconds",
+		Help:      "Ожидание глобального лимитера перед вызовом Telegram API по полосе (interactive / bulk).",
+		Buckets:   []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 30, 120},
+	}, []string{"lane"})
+
+	TelegramErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_api_errors_total",
+		Help:      "Ошибки Telegram API по коду (network — ответа не было).",
+	}, []string{"code"})
+
+	DrawOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "draw_outcomes_total",
+		Help:      "Исходы розыгрышей; pack — ID выигранного пака.",
+	}, []string{"outcome", "pack"})
+)
+
+// Исходы розыгрыша для DrawOutcomes
+const (
+	DrawWon            = "won"
+	DrawAlreadyClaimed = "already_claimed"
+	DrawNoPacks        = "no_packs"
+	DrawNotSubscribed  = "not_subscribed"
+	DrawOutsideWindow  = "outside_window"
+	DrawError          = "error"
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
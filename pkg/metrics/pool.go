@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector снимает pool.Stat() в момент скрейпа
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquired, idle, total, max                   *prometheus.Desc
+	acquireCount, acquireDuration                *prometheus.Desc
+	emptyAcquire, canceledAcquire                *prometheus.Desc
+	newConns, maxLifetimeDestroy, maxIdleDestroy *prometheus.Desc
+}
+
+// RegisterPool публикует статистику пула соединений с Postgres
+func RegisterPool(pool *pgxpool.Pool) {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pgxpool", name), help, nil, nil)
+	}
+	prometheus.MustRegister(&poolCollector{
+		pool:               pool,
+		acquired:           desc("acquired_conns", "Соединения, занятые сейчас."),
+		idle:               desc("idle_conns", "Свободные соединения."),
+		total:              desc("total_conns", "Все соединения пула."),
+		max:                desc("max_conns", "Максимум соединений."),
+		acquireCount:       desc("acquire_total", "Успешные захваты соединения."),
+		acquireDuration:    desc("acquire_duration_seconds_total", "Суммарное время захвата соединения."),
+		emptyAcquire:       desc("empty_acquire_total", "Захваты, которым пришлось ждать соединение."),
+		canceledAcquire:    desc("canceled_acquire_total", "Захваты, отменённые контекстом."),
+		newConns:           desc("new_conns_total", "Открытые соединения."),
+		maxLifetimeDestroy: desc("max_lifetime_destroy_total", "Закрытые по MaxConnLifetime."),
+		maxIdleDestroy:     desc("max_idle_destroy_total", "Закрытые по MaxConnIdleTime."),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge(c.acquired, float64(s.AcquiredConns()))
+	gauge(c.idle, float64(s.IdleConns()))
+	gauge(c.total, float64(s.TotalConns()))
+	gauge(c.max, float64(s.MaxConns()))
+	counter(c.acquireCount, float64(s.AcquireCount()))
+	counter(c.acquireDuration, s.AcquireDuration().Seconds())
+	counter(c.emptyAcquire, float64(s.EmptyAcquireCount()))
+	counter(c.canceledAcquire, float64(s.CanceledAcquireCount()))
+	counter(c.newConns, float64(s.NewConnsCount()))
+	counter(c.maxLifetimeDestroy, float64(s.MaxLifetimeDestroyCount()))
+	counter(c.maxIdleDestroy, float64(s.MaxIdleDestroyCount()))
+}
@@ -1,14 +1,167 @@
 package models
 
+import "time"
+
+const (
+	PackKindLink  = "link"  // всем победителям один общий url
+	PackKindCodes = "codes" // каждому победителю свой код из pack_codes
+)
+
+// Campaign — отдельный розыгрыш со своим пулом призов, сроками и текстами.
+// Пустые тексты и нулевой канал означают значения по умолчанию.
+type Campaign struct {
+	ID             int
+	Name           string
+	StartsAt       *time.Time // nil — черновик
+	EndsAt         *time.Time // nil — без даты окончания
+	StartText      string
+	WinText        string
+	UpsellText     string
+	AnnounceText   string
+	SubChannelID   int64
+	SubChannelLink string
+}
+
 type StickerPack struct {
-	ID      int
-	Name    string
-	URL     string
-	Deleted bool
+	ID         int
+	CampaignID int
+	Name       string
+	URL        string
+	Kind       string
+	Weight     int    // относительный шанс выпадения, 0 — не выпадает
+	Stock      *int   // остаток; nil — без ограничения
+	FreeCodes  int    // невыданные коды (только для PackKindCodes)
+	Code       string // выданный победителю код (заполняется при клейме)
+	Deleted    bool
+}
+
+// PackImport — приз из файла массового импорта; Stock nil — без ограничения
+type PackImport struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Stock  *int   `json:"stock"`
 }
 
 type UserClaim struct {
-	UserID int64
+	CampaignID int
+	UserID     int64
+	PackID     *int       // nil — клейм до появления pack_id или пак удалён
+	ClaimedAt  *time.Time // nil — клейм до появления claimed_at
+}
+
+const (
+	BroadcastDraft     = "draft"
+	BroadcastRunning   = "running"
+	BroadcastDone      = "done"
+	BroadcastCancelled = "cancelled"
+)
+
+// Broadcast — рассылка по bot_users: копия сообщения админа или готовый текст
+type Broadcast struct {
+	ID          int
+	CreatedBy   *int64 // nil — системная рассылка
+	FromChatID  int64
+	MessageID   int
+	Text        string
+	ReplyMarkup []byte // JSON InlineKeyboardMarkup для текстовой рассылки
+	Status      string
+}
+
+// OutboxMessage — отложенное текстовое сообщение из outbox
+type OutboxMessage struct {
+	ID          int64
+	ChatID      int64
+	Text        string
+	ParseMode   string
+	ReplyMarkup []byte        // JSON InlineKeyboardMarkup
+	Delay       time.Duration // при постановке: отправить не раньше чем через Delay
+	Attempts    int
+}
+
+type BroadcastStats struct {
+	Total   int
+	Sent    int
+	Failed  int
+	Pending int
+}
+
+// ExportRow — строка выгрузки: пользователь и, если есть, его клейм
+type ExportRow struct {
+	UserID    int64
+	Username  string
+	JoinedAt  *time.Time // nil — выиграл, не нажимая /start
+	Campaign  string
+	PackName  string
+	ClaimedAt *time.Time
+}
+
+// PeriodStats — новые пользователи и клеймы за период
+type PeriodStats struct {
+	Users  int
+	Claims int
+}
+
+type PackStats struct {
+	PackID  int
+	Name    string
+	Deleted bool // в корзине, но клеймы остались
+	Claims  int
+}
+
+// CampaignStats — сводка для /stats. Пользователи общие для бота, клеймы — по кампании.
+type CampaignStats struct {
+	Today     PeriodStats
+	Week      PeriodStats
+	All       PeriodStats
+	Claimants int // разных пользователей с клеймом в кампании
+	// Конверсия в окне кампании: пришли в бота за время кампании и сколько из них выиграли
+	WindowUsers     int
+	WindowClaimants int
+	Packs           []PackStats
+}
+
+// SegmentFilter — условия отбора bot_users; пустые поля не ограничивают, заданные объединяются по И
+type SegmentFilter struct {
+	NeverClaimed  bool       `json:"never_claimed,omitempty"`   // ни разу ничего не выигрывали
+	ClaimedPackID int        `json:"claimed_pack_id,omitempty"` // выиграли конкретный пак
+	JoinedFrom    *time.Time `json:"joined_from,omitempty"`     // нажали /start не раньше
+	JoinedTo      *time.Time `json:"joined_to,omitempty"`       // и строго раньше
+	Language      string     `json:"language,omitempty"`        // язык клиента Telegram, например "ru"
+}
+
+type Segment struct {
+	ID     int
+	Name   string
+	Filter SegmentFilter
+}
+
+// AdminRole — уровень доступа админа; пустая строка — не админ
+type AdminRole string
+
+const (
+	RoleOwner  AdminRole = "owner"  // всё, включая управление админами
+	RoleEditor AdminRole = "editor" // призы и кампании
+	RoleViewer AdminRole = "viewer" // только просмотр
+)
+
+type Admin struct {
+	UserID    int64
+	Role      AdminRole
+	AddedBy   *int64
+	CreatedAt time.Time
+}
+
+// AuditEntry — запись журнала действий админов; Before/After — JSON-снимки строки
+type AuditEntry struct {
+	ID        int64
+	AdminID   *int64 // nil — действие системы
+	Action    string
+	Entity    string
+	EntityID  int64
+	Before    []byte
+	After     []byte
+	CreatedAt time.Time
 }
 
 type AdminState struct {
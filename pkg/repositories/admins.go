@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+)
+
+func (r *Repository) GetAdmins(ctx context.Context) ([]models.Admin, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT user_id, role, added_by, created_at FROM admins ORDER BY created_at, user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Admin
+	for rows.Next() {
+		var a models.Admin
+		if err := rows.Scan(&a.UserID, &a.Role, &a.AddedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+func (r *Repository) UpsertAdmin(ctx context.Context, userID int64, role models.AdminRole, addedBy int64) error {
+	return r.audited(ctx, auditAdmin, AuditUpdate, userID, func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `
+			INSERT INTO admins (user_id, role, added_by) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id) DO UPDATE SET role=$2`,
+			userID, role, addedBy)
+		return err
+	})
+}
+
+func (r *Repository) DeleteAdmin(ctx context.Context, userID int64) error {
+	return r.audited(ctx, auditAdmin, AuditDelete, userID, func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `DELETE FROM admins WHERE user_id=$1`, userID)
+		return err
+	})
+}
+
+// EnsureOwner делает админа из конфига владельцем, чтобы бот никогда
+// не остался без того, кто может управлять админами
+func (r *Repository) EnsureOwner(ctx context.Context, userID int64) error {
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO admins (user_id, role) VALUES ($1, 'owner')
+		ON CONFLICT (user_id) DO UPDATE SET role='owner'`, userID)
+	return err
+}
@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Действия в admin_audit
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// auditEntity — что журналируется и как снять снимок строки по id ($1)
+type auditEntity struct {
+	name     string
+	snapshot string
+}
+
+var (
+	auditPack = auditEntity{"pack", `
+		SELECT to_jsonb(p) || jsonb_build_object('free_codes',
+		       (SELECT COUNT(*) FROM pack_codes c WHERE c.pack_id = p.id AND c.claimed_by IS NULL))
+		FROM sticker_packs p WHERE p.id=$1`}
+	auditCampaign  = auditEntity{"campaign", `SELECT to_jsonb(c) FROM campaigns c WHERE c.id=$1`}
+	auditAdmin     = auditEntity{"admin", `SELECT to_jsonb(a) FROM admins a WHERE a.user_id=$1`}
+	auditSegment   = auditEntity{"segment", `SELECT to_jsonb(s) FROM segments s WHERE s.id=$1`}
+	auditBroadcast = auditEntity{"broadcast", `SELECT to_jsonb(b) - 'reply_markup' FROM broadcasts b WHERE b.id=$1`}
+)
+
+type actorKey struct{}
+
+// WithActor помечает контекст автором изменений: всё, что репозиторий
+// поменяет с этим контекстом, попадёт в admin_audit от его имени
+func WithActor(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+func actorFrom(ctx context.Context) *int64 {
+	if id, ok := ctx.Value(actorKey{}).(int64); ok {
+		return &id
+	}
+	return nil
+}
+
+func (r *Repository) snapshot(ctx context.Context, e auditEntity, id int64) ([]byte, error) {
+	var data []byte
+	err := r.DB.QueryRow(ctx, e.snapshot, id).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// audited выполняет fn в транзакции и пишет в admin_audit снимки строки до и после.
+// Если строка не изменилась, запись не делается.
+func (r *Repository) audited(ctx context.Context, e auditEntity, action string, id int64, fn func(tx *Repository) error) error {
+	return r.WithTx(ctx, func(tx *Repository) error {
+		before, err := tx.snapshot(ctx, e, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		after, err := tx.snapshot(ctx, e, id)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(before, after) {
+			return nil
+		}
+		if before == nil {
+			action = AuditCreate // upsert создал строку
+		}
+		return tx.addAudit(ctx, action, e.name, id, before, after)
+	})
+}
+
+// auditedCreate — то же для создания: id новой строки возвращает fn
+func (r *Repository) auditedCreate(ctx context.Context, e auditEntity, fn func(tx *Repository) (int64, error)) error {
+	return r.WithTx(ctx, func(tx *Repository) error {
+		id, err := fn(tx)
+		if err != nil {
+			return err
+		}
+		after, err := tx.snapshot(ctx, e, id)
+		if err != nil {
+			return err
+		}
+		return tx.addAudit(ctx, AuditCreate, e.name, id, nil, after)
+	})
+}
+
+func (r *Repository) addAudit(ctx context.Context, action, entity string, id int64, before, after []byte) error {
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO admin_audit (admin_id, action, entity, entity_id, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		actorFrom(ctx), action, entity, id, before, after)
+	return err
+}
+
+// GetAudit — страница журнала по курсору id, ближайшие к курсору записи первыми:
+// newerThan > 0 — записи новее него по возрастанию id, иначе — старше olderThan
+// (0 — с самой новой) по убыванию
+func (r *Repository) GetAudit(ctx context.Context, limit int, olderThan, newerThan int64) ([]models.AuditEntry, error) {
+	query := `
+		SELECT id, admin_id, action, entity, entity_id, before, after, created_at
+		FROM admin_audit
+		WHERE $2 = 0 OR id < $2
+		ORDER BY id DESC
+		LIMIT $1`
+	cursor := olderThan
+	if newerThan > 0 {
+		query = `
+		SELECT id, admin_id, action, entity, entity_id, before, after, created_at
+		FROM admin_audit
+		WHERE id > $2
+		ORDER BY id
+		LIMIT $1`
+		cursor = newerThan
+	}
+	rows, err := r.DB.Query(ctx, query, limit, cursor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(&e.ID, &e.AdminID, &e.Action, &e.Entity, &e.EntityID, &e.Before, &e.After, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,221 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrNoBroadcast       = errors.New("no_broadcast")
+	ErrBroadcastNotDraft = errors.New("broadcast_not_draft")
+)
+
+const broadcastColumns = `id, created_by, COALESCE(from_chat_id, 0), COALESCE(message_id, 0),
+	COALESCE(text, ''), reply_markup, status`
+
+func scanBroadcast(row pgx.Row) (models.Broadcast, error) {
+	var b models.Broadcast
+	err := row.Scan(&b.ID, &b.CreatedBy, &b.FromChatID, &b.MessageID, &b.Text, &b.ReplyMarkup, &b.Status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Broadcast{}, ErrNoBroadcast
+	}
+	return b, err
+}
+
+// CreateBroadcastDraft сохраняет сообщение админа, которое потом скопируем всем
+func (r *Repository) CreateBroadcastDraft(ctx context.Context, createdBy, fromChatID int64, messageID int) (int, error) {
+	var id int
+	err := r.auditedCreate(ctx, auditBroadcast, func(tx *Repository) (int64, error) {
+		err := tx.DB.QueryRow(ctx, `
+			INSERT INTO broadcasts (created_by, from_chat_id, message_id) VALUES ($1, $2, $3)
+			RETURNING id`, createdBy, fromChatID, messageID).
+			Scan(&id)
+		return int64(id), err
+	})
+	return id, err
+}
+
+// CreateTextBroadcast создаёт и сразу запускает системную рассылку готового текста
+func (r *Repository) CreateTextBroadcast(ctx context.Context, text string, replyMarkup []byte) (int, error) {
+	var id int
+	err := r.WithTx(ctx, func(tx *Repository) error {
+		if err := tx.DB.QueryRow(ctx, `
+			INSERT INTO broadcasts (text, reply_markup, status, started_at)
+			VALUES ($1, $2, 'running', now())
+			RETURNING id`, text, replyMarkup).
+			Scan(&id); err != nil {
+			return err
+		}
+		_, err := tx.addDeliveries(ctx, id, models.SegmentFilter{})
+		return err
+	})
+	return id, err
+}
+
+// StartBroadcast переводит черновик в работу и фиксирует список получателей:
+// всех bot_users или только сегмент (segmentID 0 — всем). Возвращает число получателей.
+func (r *Repository) StartBroadcast(ctx context.Context, id, segmentID int) (int, error) {
+	var total int
+	err := r.audited(ctx, auditBroadcast, AuditUpdate, int64(id), func(tx *Repository) error {
+		var filter models.SegmentFilter
+		if segmentID != 0 {
+			seg, err := tx.GetSegment(ctx, segmentID)
+			if err != nil {
+				return err
+			}
+			filter = seg.Filter
+		}
+		ct, err := tx.DB.Exec(ctx, `
+			UPDATE broadcasts SET status='running', started_at=now(), segment_id=NULLIF($2, 0)
+			WHERE id=$1 AND status='draft'`, id, segmentID)
+		if err != nil {
+			return err
+		}
+		if ct.RowsAffected() == 0 {
+			return ErrBroadcastNotDraft
+		}
+		total, err = tx.addDeliveries(ctx, id, filter)
+		return err
+	})
+	return total, err
+}
+
+func (r *Repository) addDeliveries(ctx context.Context, id int, f models.SegmentFilter) (int, error) {
+	where, args := segmentWhere(f, 2)
+	ct, err := r.DB.Exec(ctx, `
+		INSERT INTO broadcast_deliveries (broadcast_id, user_id)
+		SELECT $1, u.user_id FROM bot_users u
+		WHERE `+where+`
+		ON CONFLICT DO NOTHING`, append([]any{id}, args...)...)
+	return int(ct.RowsAffected()), err
+}
+
+func (r *Repository) CancelBroadcast(ctx context.Context, id int) error {
+	return r.audited(ctx, auditBroadcast, AuditUpdate, int64(id), func(tx *Repository) error {
+		ct, err := tx.DB.Exec(ctx, `
+			UPDATE broadcasts SET status='cancelled', finished_at=now()
+			WHERE id=$1 AND status IN ('draft', 'running')`, id)
+		if err == nil && ct.RowsAffected() == 0 {
+			return ErrNoBroadcast
+		}
+		return err
+	})
+}
+
+// FinishBroadcast завершает рассылку, если все получатели обработаны. false — рассылка
+// уже не идёт или кого-то ещё отправляет другой экземпляр: завершит он.
+func (r *Repository) FinishBroadcast(ctx context.Context, id int) (bool, error) {
+	ct, err := r.DB.Exec(ctx, `
+		UPDATE broadcasts SET status='done', finished_at=now()
+		WHERE id=$1 AND status='running' AND NOT EXISTS (
+			SELECT 1 FROM broadcast_deliveries
+			WHERE broadcast_id=$1 AND status IN ('pending', 'sending'))`, id)
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() == 1, nil
+}
+
+func (r *Repository) GetBroadcast(ctx context.Context, id int) (models.Broadcast, error) {
+	return scanBroadcast(r.DB.QueryRow(ctx,
+		`SELECT `+broadcastColumns+` FROM broadcasts WHERE id=$1`, id))
+}
+
+func (r *Repository) GetRunningBroadcasts(ctx context.Context) ([]models.Broadcast, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT `+broadcastColumns+` FROM broadcasts WHERE status='running' ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Broadcast
+	for rows.Next() {
+		b, err := scanBroadcast(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	return list, rows.Err()
+}
+
+// ClaimDeliveries берёт в отправку на lease следующую пачку получателей, которым ещё
+// не отправляли, и тех, чья аренда истекла (экземпляр упал). Получатели, которых
+// в этот момент берёт другой экземпляр, пропускаются.
+func (r *Repository) ClaimDeliveries(ctx context.Context, id, limit int, lease time.Duration) ([]int64, error) {
+	rows, err := r.DB.Query(ctx, `
+		WITH batch AS (
+			SELECT broadcast_id, user_id FROM broadcast_deliveries
+			WHERE broadcast_id=$1 AND (status='pending' OR (status='sending' AND lease_until < now()))
+			ORDER BY user_id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE broadcast_deliveries d SET status='sending', lease_until=now() + make_interval(secs => $3)
+		FROM batch WHERE d.broadcast_id = batch.broadcast_id AND d.user_id = batch.user_id
+		RETURNING d.user_id`, id, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING не гарантирует порядок
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// MarkDelivery фиксирует результат отправки; errText пустой — доставлено
+func (r *Repository) MarkDelivery(ctx context.Context, id int, userID int64, errText string) error {
+	status := "sent"
+	if errText != "" {
+		status = "failed"
+	}
+	_, err := r.DB.Exec(ctx, `
+		UPDATE broadcast_deliveries SET status=$3, error=NULLIF($4, ''), sent_at=now(), lease_until=NULL
+		WHERE broadcast_id=$1 AND user_id=$2`, id, userID, status, errText)
+	return err
+}
+
+// ReleaseDeliveries возвращает взятых, но не обработанных получателей в очередь
+func (r *Repository) ReleaseDeliveries(ctx context.Context, id int, userIDs []int64) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE broadcast_deliveries SET status='pending', lease_until=NULL
+		WHERE broadcast_id=$1 AND user_id = ANY($2) AND status='sending'`, id, userIDs)
+	return err
+}
+
+func (r *Repository) GetBroadcastStats(ctx context.Context, id int) (models.BroadcastStats, error) {
+	var st models.BroadcastStats
+	err := r.DB.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE status='sent'),
+		       COUNT(*) FILTER (WHERE status='failed'),
+		       COUNT(*) FILTER (WHERE status IN ('pending', 'sending'))
+		FROM broadcast_deliveries WHERE broadcast_id=$1`, id).
+		Scan(&st.Total, &st.Sent, &st.Failed, &st.Pending)
+	return st, err
+}
+
+// CountBotUsers — сколько пользователей получат рассылку (без заблокировавших бота)
+func (r *Repository) CountBotUsers(ctx context.Context) (int, error) {
+	var n int
+	err := r.DB.QueryRow(ctx, `SELECT COUNT(*) FROM bot_users WHERE blocked_at IS NULL`).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,174 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrNoCampaign = errors.New("no_campaign")
+
+// Тексты кампании, которые админ может переопределить
+const (
+	CampaignTextStart    = "start"
+	CampaignTextWin      = "win"
+	CampaignTextUpsell   = "upsell"
+	CampaignTextAnnounce = "announce"
+)
+
+var campaignTextColumns = map[string]string{
+	CampaignTextStart:    "start_text",
+	CampaignTextWin:      "win_text",
+	CampaignTextUpsell:   "upsell_text",
+	CampaignTextAnnounce: "announce_text",
+}
+
+const campaignColumns = `id, name, starts_at, ends_at,
+	COALESCE(start_text, ''), COALESCE(win_text, ''), COALESCE(upsell_text, ''), COALESCE(announce_text, ''),
+	COALESCE(sub_channel_id, 0), COALESCE(sub_channel_link, '')`
+
+func scanCampaign(row pgx.Row) (models.Campaign, error) {
+	var c models.Campaign
+	err := row.Scan(&c.ID, &c.Name, &c.StartsAt, &c.EndsAt,
+		&c.StartText, &c.WinText, &c.UpsellText, &c.AnnounceText,
+		&c.SubChannelID, &c.SubChannelLink)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Campaign{}, ErrNoCampaign
+	}
+	return c, err
+}
+
+// CreateCampaign создаёт черновик кампании без дат
+func (r *Repository) CreateCampaign(ctx context.Context, name string) (int, error) {
+	var id int
+	err := r.auditedCreate(ctx, auditCampaign, func(tx *Repository) (int64, error) {
+		err := tx.DB.QueryRow(ctx,
+			`INSERT INTO campaigns (name) VALUES ($1) RETURNING id`, name).
+			Scan(&id)
+		return int64(id), err
+	})
+	return id, err
+}
+
+func (r *Repository) GetCampaign(ctx context.Context, id int) (models.Campaign, error) {
+	return scanCampaign(r.DB.QueryRow(ctx,
+		`SELECT `+campaignColumns+` FROM campaigns WHERE id=$1`, id))
+}
+
+// GetCurrentCampaign — идущая сейчас кампания; если окна пересекаются,
+// побеждает начавшаяся последней.
+func (r *Repository) GetCurrentCampaign(ctx context.Context) (models.Campaign, error) {
+	return scanCampaign(r.DB.QueryRow(ctx, `
+		SELECT `+campaignColumns+` FROM campaigns
+		WHERE starts_at <= now() AND (ends_at IS NULL OR ends_at > now())
+		ORDER BY starts_at DESC
+		LIMIT 1`))
+}
+
+// GetNextCampaign — ближайшая ещё не начавшаяся кампания
+func (r *Repository) GetNextCampaign(ctx context.Context) (models.Campaign, error) {
+	return scanCampaign(r.DB.QueryRow(ctx, `
+		SELECT `+campaignColumns+` FROM campaigns
+		WHERE starts_at > now()
+		ORDER BY starts_at
+		LIMIT 1`))
+}
+
+// GetLastEndedCampaign — последняя завершившаяся кампания
+func (r *Repository) GetLastEndedCampaign(ctx context.Context) (models.Campaign, error) {
+	return scanCampaign(r.DB.QueryRow(ctx, `
+		SELECT `+campaignColumns+` FROM campaigns
+		WHERE ends_at <= now()
+		ORDER BY ends_at DESC
+		LIMIT 1`))
+}
+
+// TakeCampaignsToAnnounce помечает начавшиеся, но ещё не анонсированные
+// кампании как анонсированные и возвращает их. Пометка ставится до рассылки,
+// поэтому при нескольких экземплярах бота анонс уйдёт только один раз.
+func (r *Repository) TakeCampaignsToAnnounce(ctx context.Context) ([]models.Campaign, error) {
+	rows, err := r.DB.Query(ctx, `
+		UPDATE campaigns SET announced_at = now()
+		WHERE announced_at IS NULL
+		  AND starts_at <= now()
+		  AND (ends_at IS NULL OR ends_at > now())
+		RETURNING `+campaignColumns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Campaign
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+func (r *Repository) GetCampaigns(ctx context.Context) ([]models.Campaign, error) {
+	rows, err := r.DB.Query(ctx, `SELECT `+campaignColumns+` FROM campaigns ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Campaign
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+func (r *Repository) UpdateCampaignName(ctx context.Context, id int, name string) error {
+	return r.audited(ctx, auditCampaign, AuditUpdate, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `UPDATE campaigns SET name=$1 WHERE id=$2`, name, id)
+		return err
+	})
+}
+
+// UpdateCampaignSchedule меняет окно кампании. Перенос старта в будущее
+// сбрасывает анонс, чтобы о новом старте снова оповестили пользователей.
+func (r *Repository) UpdateCampaignSchedule(ctx context.Context, id int, startsAt, endsAt *time.Time) error {
+	return r.audited(ctx, auditCampaign, AuditUpdate, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `
+			UPDATE campaigns SET starts_at=$1, ends_at=$2,
+				announced_at = CASE WHEN $1::timestamptz IS NULL OR $1 > now() THEN NULL ELSE announced_at END
+			WHERE id=$3`, startsAt, endsAt, id)
+		return err
+	})
+}
+
+// UpdateCampaignText меняет один из текстов кампании; пустой текст — вернуть текст по умолчанию
+func (r *Repository) UpdateCampaignText(ctx context.Context, id int, kind, text string) error {
+	col, ok := campaignTextColumns[kind]
+	if !ok {
+		return fmt.Errorf("unknown campaign text %q", kind)
+	}
+	return r.audited(ctx, auditCampaign, AuditUpdate, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx,
+			`UPDATE campaigns SET `+col+`=NULLIF($1, '') WHERE id=$2`, text, id)
+		return err
+	})
+}
+
+// UpdateCampaignChannel задаёт канал для проверки подписки; 0 — канал из конфига
+func (r *Repository) UpdateCampaignChannel(ctx context.Context, id int, channelID int64, link string) error {
+	return r.audited(ctx, auditCampaign, AuditUpdate, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `
+			UPDATE campaigns SET sub_channel_id=NULLIF($1, 0), sub_channel_link=NULLIF($2, '')
+			WHERE id=$3`, channelID, link, id)
+		return err
+	})
+}
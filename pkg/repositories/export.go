@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+)
+
+// ExportRows проходит по всем пользователям и их клеймам — по строке на клейм,
+// пользователи без клеймов тоже попадают. Строки отдаются в fn по мере чтения.
+func (r *Repository) ExportRows(ctx context.Context, fn func(models.ExportRow) error) error {
+	rows, err := r.DB.Query(ctx, `
+		SELECT COALESCE(u.user_id, uc.user_id), COALESCE(u.username, ''), u.created_at,
+		       COALESCE(c.name, ''), COALESCE(sp.name, ''), uc.claimed_at
+		FROM bot_users u
+		FULL JOIN user_claims uc ON uc.user_id = u.user_id
+		LEFT JOIN campaigns c ON c.id = uc.campaign_id
+		LEFT JOIN sticker_packs sp ON sp.id = uc.pack_id
+		ORDER BY 1, uc.claimed_at NULLS FIRST`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row models.ExportRow
+		if err := rows.Scan(&row.UserID, &row.Username, &row.JoinedAt, &row.Campaign, &row.PackName, &row.ClaimedAt); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+)
+
+// EnqueueOutbox ставит сообщение в outbox; вызывается в той же транзакции, что и клейм.
+// Время отправки считает БД (now() + Delay), как и все остальные сроки outbox.
+func (r *Repository) EnqueueOutbox(ctx context.Context, m models.OutboxMessage) (int64, error) {
+	var id int64
+	err := r.DB.QueryRow(ctx, `
+		INSERT INTO outbox (chat_id, text, parse_mode, reply_markup, send_after)
+		VALUES ($1, $2, $3, $4, now() + make_interval(secs => $5))
+		RETURNING id`,
+		m.ChatID, m.Text, m.ParseMode, m.ReplyMarkup, m.Delay.Seconds()).Scan(&id)
+	return id, err
+}
+
+// ScheduleOutbox переносит отправку ещё не взятого сообщения на now() + delay
+func (r *Repository) ScheduleOutbox(ctx context.Context, id int64, delay time.Duration) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE outbox SET send_after = now() + make_interval(secs => $2)
+		WHERE id = $1 AND status = 'pending'`, id, delay.Seconds())
+	return err
+}
+
+// ClaimDueOutbox берёт в отправку на lease первое неотправленное сообщение каждого чата,
+// если его время пришло. Первое — по порядку постановки (id), а не по send_after: повтор
+// после ошибки отодвигает send_after, но следующее сообщение чата всё равно не уйдёт,
+// пока предыдущее не отправлено (или не отброшено). Сообщение, которое отправляет другой
+// экземпляр, не берётся, пока не истекла его аренда.
+func (r *Repository) ClaimDueOutbox(ctx context.Context, lease time.Duration, limit int) ([]models.OutboxMessage, error) {
+	rows, err := r.DB.Query(ctx, `
+		WITH head AS (
+			SELECT DISTINCT ON (chat_id) id
+			FROM outbox
+			WHERE status IN ('pending', 'sending')
+			ORDER BY chat_id, id
+		), due AS (
+			SELECT o.id FROM outbox o JOIN head ON head.id = o.id
+			WHERE o.send_after <= now() AND (o.status = 'pending' OR o.lease_until < now())
+			ORDER BY o.send_after, o.id
+			LIMIT $2
+			FOR UPDATE OF o SKIP LOCKED
+		)
+		UPDATE outbox o SET status = 'sending', lease_until = now() + make_interval(secs => $1)
+		FROM due WHERE o.id = due.id
+		RETURNING o.id, o.chat_id, o.text, o.parse_mode, o.reply_markup, o.attempts`, lease.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.OutboxMessage
+	for rows.Next() {
+		var m models.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.ChatID, &m.Text, &m.ParseMode, &m.ReplyMarkup, &m.Attempts); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) MarkOutboxSent(ctx context.Context, id int64) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE outbox SET status='sent', sent_at=now(), attempts=attempts+1, lease_until=NULL WHERE id=$1`, id)
+	return err
+}
+
+// RetryOutbox возвращает сообщение в очередь через backoff после неудачной попытки
+func (r *Repository) RetryOutbox(ctx context.Context, id int64, errText string, backoff time.Duration) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE outbox SET status='pending', attempts=attempts+1, last_error=$2, lease_until=NULL,
+			send_after = now() + make_interval(secs => $3)
+		WHERE id=$1`, id, errText, backoff.Seconds())
+	return err
+}
+
+// ReleaseOutbox — остановка посреди отправки: сообщение сразу снова доступно, попытка не считается
+func (r *Repository) ReleaseOutbox(ctx context.Context, id int64) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE outbox SET status='pending', lease_until=NULL WHERE id=$1 AND status='sending'`, id)
+	return err
+}
+
+// FailOutbox — отправить не получится (бот заблокирован, попытки кончились)
+func (r *Repository) FailOutbox(ctx context.Context, id int64, errText string) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE outbox SET status='failed', attempts=attempts+1, last_error=$2, lease_until=NULL WHERE id=$1`,
+		id, errText)
+	return err
+}
+
+// PurgeSentOutbox удаляет отправленные сообщения старше keep; неудачные остаются для разбора
+func (r *Repository) PurgeSentOutbox(ctx context.Context, keep time.Duration) (int, error) {
+	ct, err := r.DB.Exec(ctx, `DELETE FROM outbox WHERE status='sent' AND sent_at < $1`, time.Now().Add(-keep))
+	if err != nil {
+		return 0, err
+	}
+	return int(ct.RowsAffected()), nil
+}
@@ -3,41 +3,210 @@ package repositories
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/rand"
 	"time"
 
 	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var ErrNoPacks = errors.New("no_packs")
+var (
+	ErrNoPacks = errors.New("no_packs")
+	ErrNoClaim = errors.New("no_claim")
+	// ErrPrizeGone — клейм есть, но выигранный пак удалён навсегда
+	ErrPrizeGone = errors.New("prize_gone")
+	// ErrPackNameTaken — восстановить нельзя: в кампании уже есть пак с таким именем
+	ErrPackNameTaken = errors.New("pack_name_taken")
+)
 
 func init() { rand.Seed(time.Now().UnixNano()) }
 
+// DBTX — общее подмножество pgxpool.Pool и pgx.Tx,
+// чтобы одни и те же методы работали и в транзакции, и без неё.
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
 type Repository struct {
-	DB *pgxpool.Pool
+	DB DBTX
 }
 
 func NewRepository(db *pgxpool.Pool) *Repository { return &Repository{DB: db} }
 
-func (r *Repository) CreateStickerPack(ctx context.Context, name, url string) error {
-	_, err := r.DB.Exec(ctx, `INSERT INTO sticker_packs (name, url) VALUES ($1, $2)`, name, url)
-	return err
+// WithTx выполняет fn в транзакции: коммит при nil, откат при любой ошибке.
+func (r *Repository) WithTx(ctx context.Context, fn func(tx *Repository) error) error {
+	return pgx.BeginFunc(ctx, r.DB, func(tx pgx.Tx) error {
+		return fn(&Repository{DB: tx})
+	})
+}
+
+func (r *Repository) CreateStickerPack(ctx context.Context, campaignID int, name, url string, weight int) (int, error) {
+	var id int
+	err := r.auditedCreate(ctx, auditPack, func(tx *Repository) (int64, error) {
+		err := tx.DB.QueryRow(ctx,
+			`INSERT INTO sticker_packs (campaign_id, name, url, weight) VALUES ($1, $2, $3, $4) RETURNING id`,
+			campaignID, name, url, weight).
+			Scan(&id)
+		return int64(id), err
+	})
+	return id, err
+}
+
+// ImportStickerPacks добавляет призы в кампанию одной транзакцией: либо все, либо ни одного
+func (r *Repository) ImportStickerPacks(ctx context.Context, campaignID int, packs []models.PackImport) error {
+	return r.WithTx(ctx, func(tx *Repository) error {
+		for _, p := range packs {
+			err := tx.auditedCreate(ctx, auditPack, func(tx *Repository) (int64, error) {
+				var id int64
+				err := tx.DB.QueryRow(ctx, `
+					INSERT INTO sticker_packs (campaign_id, name, url, weight, stock) VALUES ($1, $2, $3, $4, $5)
+					RETURNING id`,
+					campaignID, p.Name, p.URL, p.Weight, p.Stock).
+					Scan(&id)
+				return id, err
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", p.Name, err)
+			}
+		}
+		return nil
+	})
 }
 
 func (r *Repository) UpdateStickerPack(ctx context.Context, id int, name, url string) error {
-	_, err := r.DB.Exec(ctx, `UPDATE sticker_packs SET name=$1, url=$2 WHERE id=$3`, name, url, id)
-	return err
+	return r.audited(ctx, auditPack, AuditUpdate, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `UPDATE sticker_packs SET name=$1, url=$2 WHERE id=$3`, name, url, id)
+		return err
+	})
+}
+
+func (r *Repository) UpdateStickerPackWeight(ctx context.Context, id, weight int) error {
+	return r.audited(ctx, auditPack, AuditUpdate, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `UPDATE sticker_packs SET weight=$1 WHERE id=$2`, weight, id)
+		return err
+	})
+}
+
+func (r *Repository) UpdateStickerPackStock(ctx context.Context, id int, stock *int) error {
+	return r.audited(ctx, auditPack, AuditUpdate, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `UPDATE sticker_packs SET stock=$1 WHERE id=$2`, stock, id)
+		return err
+	})
+}
+
+// ReserveStock атомарно списывает одну единицу остатка.
+// ok=false — пак уже распродан (или его успели выкупить параллельно).
+func (r *Repository) ReserveStock(ctx context.Context, id int) (remaining int, ok bool, err error) {
+	err = r.DB.QueryRow(ctx,
+		`UPDATE sticker_packs SET stock = stock - 1 WHERE id=$1 AND stock > 0 RETURNING stock`, id).
+		Scan(&remaining)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	return remaining, err == nil, err
+}
+
+// AddPackCodes загружает коды в пул пака и переводит пак в режим кодов.
+// Уже существующие коды пропускаются; возвращает число добавленных.
+func (r *Repository) AddPackCodes(ctx context.Context, packID int, codes []string) (int, error) {
+	var added int
+	err := r.audited(ctx, auditPack, AuditUpdate, int64(packID), func(tx *Repository) error {
+		if _, err := tx.DB.Exec(ctx,
+			`UPDATE sticker_packs SET kind='codes' WHERE id=$1`, packID); err != nil {
+			return err
+		}
+		ct, err := tx.DB.Exec(ctx, `
+			INSERT INTO pack_codes (pack_id, code)
+			SELECT $1, unnest($2::text[])
+			ON CONFLICT (pack_id, code) DO NOTHING`, packID, codes)
+		if err != nil {
+			return err
+		}
+		added = int(ct.RowsAffected())
+		return nil
+	})
+	return added, err
+}
+
+// ClaimCode атомарно выдаёт пользователю один свободный код пака.
+// SKIP LOCKED не даёт двум параллельным клеймам получить один и тот же код.
+// ok=false — свободных кодов не осталось.
+func (r *Repository) ClaimCode(ctx context.Context, packID int, userID int64) (code string, ok bool, err error) {
+	err = r.DB.QueryRow(ctx, `
+		UPDATE pack_codes SET claimed_by=$2, claimed_at=now()
+		WHERE id = (
+			SELECT id FROM pack_codes
+			WHERE pack_id=$1 AND claimed_by IS NULL
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING code`, packID, userID).Scan(&code)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	return code, err == nil, err
+}
+
+func (r *Repository) CountFreeCodes(ctx context.Context, packID int) (int, error) {
+	var n int
+	err := r.DB.QueryRow(ctx,
+		`SELECT COUNT(*) FROM pack_codes WHERE pack_id=$1 AND claimed_by IS NULL`, packID).
+		Scan(&n)
+	return n, err
 }
 
+// DeleteStickerPack переносит пак в корзину: он пропадает из розыгрыша и списка,
+// но клеймы и коды остаются
 func (r *Repository) DeleteStickerPack(ctx context.Context, id int) error {
-	_, err := r.DB.Exec(ctx, `DELETE FROM sticker_packs WHERE id=$1`, id)
+	return r.audited(ctx, auditPack, AuditDelete, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `UPDATE sticker_packs SET deleted_at=now() WHERE id=$1 AND deleted_at IS NULL`, id)
+		return err
+	})
+}
+
+func (r *Repository) RestoreStickerPack(ctx context.Context, id int) error {
+	err := r.audited(ctx, auditPack, AuditRestore, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `UPDATE sticker_packs SET deleted_at=NULL WHERE id=$1`, id)
+		return err
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrPackNameTaken
+	}
 	return err
 }
 
-func (r *Repository) GetStickerPacks(ctx context.Context) ([]models.StickerPack, error) {
-	rows, err := r.DB.Query(ctx, `SELECT id, name, url FROM sticker_packs ORDER BY id`)
+// PurgeStickerPack удаляет пак из корзины навсегда; у клеймов остаётся pack_id NULL
+func (r *Repository) PurgeStickerPack(ctx context.Context, id int) error {
+	return r.audited(ctx, auditPack, AuditPurge, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `DELETE FROM sticker_packs WHERE id=$1 AND deleted_at IS NOT NULL`, id)
+		return err
+	})
+}
+
+func (r *Repository) GetStickerPacks(ctx context.Context, campaignID int) ([]models.StickerPack, error) {
+	return r.getStickerPacks(ctx, campaignID, false)
+}
+
+// GetDeletedStickerPacks — корзина кампании
+func (r *Repository) GetDeletedStickerPacks(ctx context.Context, campaignID int) ([]models.StickerPack, error) {
+	return r.getStickerPacks(ctx, campaignID, true)
+}
+
+func (r *Repository) getStickerPacks(ctx context.Context, campaignID int, deleted bool) ([]models.StickerPack, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT p.id, p.campaign_id, p.name, p.url, p.kind, p.weight, p.stock,
+		       (SELECT COUNT(*) FROM pack_codes c WHERE c.pack_id = p.id AND c.claimed_by IS NULL)
+		FROM sticker_packs p
+		WHERE p.campaign_id=$1 AND (p.deleted_at IS NOT NULL) = $2
+		ORDER BY p.id`, campaignID, deleted)
 	if err != nil {
 		return nil, err
 	}
@@ -45,8 +214,8 @@ func (r *Repository) GetStickerPacks(ctx context.Context) ([]models.StickerPack,
 
 	var list []models.StickerPack
 	for rows.Next() {
-		var p models.StickerPack
-		if err := rows.Scan(&p.ID, &p.Name, &p.URL); err != nil {
+		p := models.StickerPack{Deleted: deleted}
+		if err := rows.Scan(&p.ID, &p.CampaignID, &p.Name, &p.URL, &p.Kind, &p.Weight, &p.Stock, &p.FreeCodes); err != nil {
 			return nil, err
 		}
 		list = append(list, p)
@@ -54,45 +223,89 @@ func (r *Repository) GetStickerPacks(ctx context.Context) ([]models.StickerPack,
 	return list, rows.Err()
 }
 
-func (r *Repository) GetRandomStickerPack(ctx context.Context) (models.StickerPack, error) {
+// Взвешенный случайный выбор: ключ -ln(U)/weight распределён экспоненциально,
+// поэтому минимальный ключ достаётся паку с вероятностью weight/sum(weight).
+// Участвуют только паки кампании вне корзины; распроданные паки, паки кодов без свободных
+// кодов и паки из exclude пропускаются.
+func (r *Repository) GetRandomStickerPack(ctx context.Context, campaignID int, exclude []int) (models.StickerPack, error) {
+	if exclude == nil {
+		exclude = []int{} // NULL в ALL() отфильтровал бы все строки
+	}
 	var p models.StickerPack
-	err := r.DB.QueryRow(ctx,
-		`SELECT id, name, url FROM sticker_packs ORDER BY RANDOM() LIMIT 1`).
-		Scan(&p.ID, &p.Name, &p.URL)
+	err := r.DB.QueryRow(ctx, `
+		SELECT p.id, p.campaign_id, p.name, p.url, p.kind, p.weight, p.stock FROM sticker_packs p
+		WHERE p.campaign_id = $2
+		  AND p.deleted_at IS NULL
+		  AND p.weight > 0
+		  AND (p.stock IS NULL OR p.stock > 0)
+		  AND (p.kind <> 'codes' OR EXISTS (
+		      SELECT 1 FROM pack_codes c WHERE c.pack_id = p.id AND c.claimed_by IS NULL))
+		  AND p.id <> ALL($1)
+		ORDER BY -ln(1.0 - RANDOM()) / p.weight
+		LIMIT 1`, exclude, campaignID).
+		Scan(&p.ID, &p.CampaignID, &p.Name, &p.URL, &p.Kind, &p.Weight, &p.Stock)
 
 	if errors.Is(err, pgx.ErrNoRows) {
-		return models.StickerPack{}, errors.New("список стикерпаков пуст")
+		return models.StickerPack{}, ErrNoPacks
 	}
 	return p, err
 }
 
-// Атомарная попытка получить право на стикерпак 1 раз на user_id
-func (r *Repository) TryClaim(ctx context.Context, userID int64) (bool, error) {
+// Атомарная попытка получить право на стикерпак 1 раз на user_id в кампании
+func (r *Repository) TryClaim(ctx context.Context, campaignID int, userID int64) (bool, error) {
 	ct, err := r.DB.Exec(ctx, `
-		INSERT INTO user_claims (user_id) VALUES ($1)
-		ON CONFLICT (user_id) DO NOTHING
-	`, userID)
+		INSERT INTO user_claims (campaign_id, user_id) VALUES ($1, $2)
+		ON CONFLICT (campaign_id, user_id) DO NOTHING
+	`, campaignID, userID)
 	if err != nil {
 		return false, err
 	}
 	return ct.RowsAffected() == 1, nil
 }
 
-func (r *Repository) HasUserClaimed(ctx context.Context, userID int64) bool {
-	var exists bool
-	_ = r.DB.QueryRow(ctx,
-		`SELECT EXISTS(SELECT 1 FROM user_claims WHERE user_id=$1)`, userID).
-		Scan(&exists)
-	return exists
-}
-
-func (r *Repository) MarkUserClaimed(ctx context.Context, userID int64) error {
+// SetClaimPack запоминает, какой пак выиграл пользователь
+func (r *Repository) SetClaimPack(ctx context.Context, campaignID int, userID int64, packID int) error {
 	_, err := r.DB.Exec(ctx,
-		`INSERT INTO user_claims (user_id) VALUES ($1)`,
-		userID)
+		`UPDATE user_claims SET pack_id=$3 WHERE campaign_id=$1 AND user_id=$2`,
+		campaignID, userID, packID)
 	return err
 }
 
+// GetClaimedPack возвращает пак, выигранный пользователем в кампании, вместе с выданным кодом.
+// Пак из корзины по-прежнему отдаётся: приз уже выдан.
+// ErrNoClaim — пользователь ничего не выигрывал в кампании, ErrPrizeGone — пак удалён навсегда.
+func (r *Repository) GetClaimedPack(ctx context.Context, campaignID int, userID int64) (models.StickerPack, error) {
+	var p models.StickerPack
+	var packID *int
+	var name, url, kind, code *string
+	// LEFT JOIN: после purge у клейма pack_id = NULL, но выигрыш был.
+	// Код — именно этого пака и этого пользователя; старые тестовые розыгрыши могли
+	// выдать несколько, берём последний.
+	err := r.DB.QueryRow(ctx, `
+		SELECT uc.campaign_id, p.id, p.name, p.url, p.kind, c.code
+		FROM user_claims uc
+		LEFT JOIN sticker_packs p ON p.id = uc.pack_id
+		LEFT JOIN pack_codes c ON c.pack_id = uc.pack_id AND c.claimed_by = uc.user_id
+		WHERE uc.campaign_id=$1 AND uc.user_id=$2
+		ORDER BY c.claimed_at DESC NULLS LAST, c.id DESC
+		LIMIT 1`, campaignID, userID).
+		Scan(&p.CampaignID, &packID, &name, &url, &kind, &code)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.StickerPack{}, ErrNoClaim
+	}
+	if err != nil {
+		return models.StickerPack{}, err
+	}
+	if packID == nil {
+		return p, ErrPrizeGone
+	}
+	p.ID, p.Name, p.URL, p.Kind = *packID, *name, *url, *kind
+	if code != nil {
+		p.Code = *code
+	}
+	return p, nil
+}
+
 func (r *Repository) SetAdminState(ctx context.Context, st models.AdminState) error {
 	_, err := r.DB.Exec(ctx, `
 		INSERT INTO admin_states (user_id, state, data)
@@ -118,9 +331,31 @@ func (r *Repository) ClearAdminState(ctx context.Context, userID int64) error {
 	return err
 }
 
-func (r *Repository) UpsertBotUser(ctx context.Context, userID int64) error {
+func (r *Repository) UpsertBotUser(ctx context.Context, userID int64, username, languageCode string) error {
 	_, err := r.DB.Exec(ctx,
-		`INSERT INTO bot_users (user_id) VALUES ($1)
-         ON CONFLICT (user_id) DO NOTHING`, userID)
+		`INSERT INTO bot_users (user_id, username, language_code) VALUES ($1, NULLIF($2, ''), NULLIF($3, ''))
+         ON CONFLICT (user_id) DO UPDATE
+         SET username      = COALESCE(EXCLUDED.username, bot_users.username),
+             language_code = COALESCE(EXCLUDED.language_code, bot_users.language_code),
+             blocked_at    = NULL`,
+		userID, username, languageCode)
+	return err
+}
+
+// MarkUserBlocked — Telegram ответил 403: пользователь заблокировал бота или удалён.
+// Снимается, когда пользователь снова нажмёт /start.
+func (r *Repository) MarkUserBlocked(ctx context.Context, userID int64) error {
+	_, err := r.DB.Exec(ctx, `UPDATE bot_users SET blocked_at = now() WHERE user_id = $1 AND blocked_at IS NULL`, userID)
 	return err
 }
+
+// MigrateChat переносит ссылки на группу, ставшую супергруппой, на её новый ID
+func (r *Repository) MigrateChat(ctx context.Context, from, to int64) error {
+	return r.WithTx(ctx, func(tx *Repository) error {
+		if _, err := tx.DB.Exec(ctx, `UPDATE campaigns SET sub_channel_id = $2 WHERE sub_channel_id = $1`, from, to); err != nil {
+			return err
+		}
+		_, err := tx.DB.Exec(ctx, `UPDATE outbox SET chat_id = $2 WHERE chat_id = $1 AND status IN ('pending', 'sending')`, from, to)
+		return err
+	})
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrNoSegment = errors.New("no_segment")
+
+// segmentWhere строит условие по bot_users u; параметры нумеруются с firstArg.
+// Заблокировавшие бота в сегмент не попадают.
+func segmentWhere(f models.SegmentFilter, firstArg int) (string, []any) {
+	conds := []string{"u.blocked_at IS NULL"}
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", firstArg+len(args)-1)
+	}
+
+	if f.NeverClaimed {
+		conds = append(conds, `NOT EXISTS (SELECT 1 FROM user_claims c WHERE c.user_id = u.user_id)`)
+	}
+	if f.ClaimedPackID != 0 {
+		conds = append(conds, `EXISTS (SELECT 1 FROM user_claims c WHERE c.user_id = u.user_id AND c.pack_id = `+arg(f.ClaimedPackID)+`)`)
+	}
+	if f.JoinedFrom != nil {
+		conds = append(conds, `u.created_at >= `+arg(*f.JoinedFrom))
+	}
+	if f.JoinedTo != nil {
+		conds = append(conds, `u.created_at < `+arg(*f.JoinedTo))
+	}
+	if f.Language != "" {
+		conds = append(conds, `lower(split_part(u.language_code, '-', 1)) = lower(`+arg(f.Language)+`)`)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+func (r *Repository) CreateSegment(ctx context.Context, name string, f models.SegmentFilter, createdBy int64) (int, error) {
+	var id int
+	err := r.auditedCreate(ctx, auditSegment, func(tx *Repository) (int64, error) {
+		err := tx.DB.QueryRow(ctx,
+			`INSERT INTO segments (name, filter, created_by) VALUES ($1, $2, $3) RETURNING id`,
+			name, f, createdBy).
+			Scan(&id)
+		return int64(id), err
+	})
+	return id, err
+}
+
+func (r *Repository) GetSegment(ctx context.Context, id int) (models.Segment, error) {
+	var s models.Segment
+	err := r.DB.QueryRow(ctx, `SELECT id, name, filter FROM segments WHERE id=$1`, id).
+		Scan(&s.ID, &s.Name, &s.Filter)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Segment{}, ErrNoSegment
+	}
+	return s, err
+}
+
+func (r *Repository) GetSegments(ctx context.Context) ([]models.Segment, error) {
+	rows, err := r.DB.Query(ctx, `SELECT id, name, filter FROM segments ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Segment
+	for rows.Next() {
+		var s models.Segment
+		if err := rows.Scan(&s.ID, &s.Name, &s.Filter); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+func (r *Repository) DeleteSegment(ctx context.Context, id int) error {
+	return r.audited(ctx, auditSegment, AuditDelete, int64(id), func(tx *Repository) error {
+		_, err := tx.DB.Exec(ctx, `DELETE FROM segments WHERE id=$1`, id)
+		return err
+	})
+}
+
+// CountSegment — сколько пользователей сейчас попадает под фильтр
+func (r *Repository) CountSegment(ctx context.Context, f models.SegmentFilter) (int, error) {
+	where, args := segmentWhere(f, 1)
+	var n int
+	err := r.DB.QueryRow(ctx, `SELECT COUNT(*) FROM bot_users u WHERE `+where, args...).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+)
+
+// GetCampaignStats собирает сводку по кампании. Границы «сегодня» и «неделя»
+// считает вызывающий — в часовом поясе бота. Клеймы без claimed_at (до миграции
+// 000005) попадают только в «всё время». Конверсия считается по пользователям,
+// пришедшим в окне кампании, — иначе старая аудитория бота размывает её.
+// У черновика без starts_at окна нет, там нули.
+func (r *Repository) GetCampaignStats(ctx context.Context, campaignID int, dayStart, weekStart time.Time) (models.CampaignStats, error) {
+	var st models.CampaignStats
+	err := r.DB.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM bot_users WHERE created_at >= $2),
+			(SELECT COUNT(*) FROM bot_users WHERE created_at >= $3),
+			(SELECT COUNT(*) FROM bot_users),
+			COUNT(*) FILTER (WHERE claimed_at >= $2),
+			COUNT(*) FILTER (WHERE claimed_at >= $3),
+			COUNT(*),
+			COUNT(DISTINCT user_id)
+		FROM user_claims
+		WHERE campaign_id = $1`, campaignID, dayStart, weekStart).
+		Scan(&st.Today.Users, &st.Week.Users, &st.All.Users,
+			&st.Today.Claims, &st.Week.Claims, &st.All.Claims, &st.Claimants)
+	if err != nil {
+		return st, err
+	}
+
+	err = r.DB.QueryRow(ctx, `
+		WITH joined AS (
+			SELECT u.user_id FROM bot_users u
+			JOIN campaigns c ON c.id = $1
+			WHERE u.created_at >= c.starts_at AND (c.ends_at IS NULL OR u.created_at < c.ends_at)
+		)
+		SELECT
+			(SELECT COUNT(*) FROM joined),
+			(SELECT COUNT(DISTINCT uc.user_id) FROM user_claims uc
+			 JOIN joined j ON j.user_id = uc.user_id
+			 WHERE uc.campaign_id = $1)`, campaignID).
+		Scan(&st.WindowUsers, &st.WindowClaimants)
+	if err != nil {
+		return st, err
+	}
+
+	rows, err := r.DB.Query(ctx, `
+		SELECT sp.id, sp.name, sp.deleted_at IS NOT NULL, COUNT(uc.user_id)
+		FROM sticker_packs sp
+		LEFT JOIN user_claims uc ON uc.pack_id = sp.id
+		WHERE sp.campaign_id = $1
+		GROUP BY sp.id, sp.name, sp.deleted_at
+		ORDER BY COUNT(uc.user_id) DESC, sp.id`, campaignID)
+	if err != nil {
+		return st, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.PackStats
+		if err := rows.Scan(&p.PackID, &p.Name, &p.Deleted, &p.Claims); err != nil {
+			return st, err
+		}
+		st.Packs = append(st.Packs, p)
+	}
+	return st, rows.Err()
+}
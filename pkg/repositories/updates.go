@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// EnqueueUpdate сохраняет апдейт до обработки; в работу его берёт ClaimUpdates.
+// false — такой update_id уже был (дубликат).
+func (r *Repository) EnqueueUpdate(ctx context.Context, updateID int, payload []byte) (bool, error) {
+	ct, err := r.DB.Exec(ctx, `
+		INSERT INTO update_queue (update_id, payload) VALUES ($1, $2)
+		ON CONFLICT (update_id) DO NOTHING`, updateID, payload)
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() == 1, nil
+}
+
+// ClaimUpdates берёт в работу до limit необработанных апдейтов без владельца или
+// с истёкшей арендой (экземпляр упал) по порядку update_id. Строки, которые в этот
+// момент забирает другой экземпляр, пропускаются.
+func (r *Repository) ClaimUpdates(ctx context.Context, owner string, lease time.Duration, limit int) ([][]byte, error) {
+	rows, err := r.DB.Query(ctx, `
+		WITH batch AS (
+			SELECT update_id FROM update_queue
+			WHERE processed_at IS NULL AND (owner IS NULL OR lease_until < now())
+			ORDER BY update_id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE update_queue q SET owner = $1, lease_until = now() + make_interval(secs => $2)
+		FROM batch WHERE q.update_id = batch.update_id
+		RETURNING q.update_id, q.payload`, owner, lease.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// RETURNING не гарантирует порядок
+	byID := map[int64][]byte{}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var payload []byte
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, err
+		}
+		byID[id] = payload
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(ids)
+	out := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, byID[id])
+	}
+	return out, nil
+}
+
+// RenewUpdateLeases продлевает аренду всех необработанных апдейтов экземпляра
+func (r *Repository) RenewUpdateLeases(ctx context.Context, owner string, lease time.Duration) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE update_queue SET lease_until = now() + make_interval(secs => $2)
+		WHERE owner = $1 AND processed_at IS NULL`, owner, lease.Seconds())
+	return err
+}
+
+// ReleaseUpdates — остановка: необработанное экземпляром сразу отдаётся другим.
+// Возвращает число таких апдейтов.
+func (r *Repository) ReleaseUpdates(ctx context.Context, owner string) (int, error) {
+	ct, err := r.DB.Exec(ctx, `
+		UPDATE update_queue SET owner = NULL, lease_until = NULL
+		WHERE owner = $1 AND processed_at IS NULL`, owner)
+	if err != nil {
+		return 0, err
+	}
+	return int(ct.RowsAffected()), nil
+}
+
+func (r *Repository) MarkUpdateProcessed(ctx context.Context, updateID int) error {
+	_, err := r.DB.Exec(ctx, `UPDATE update_queue SET processed_at = now() WHERE update_id = $1`, updateID)
+	return err
+}
+
+// PurgeProcessedUpdates удаляет обработанные апдейты старше keep: дольше Telegram их не повторяет
+func (r *Repository) PurgeProcessedUpdates(ctx context.Context, keep time.Duration) (int, error) {
+	ct, err := r.DB.Exec(ctx, `DELETE FROM update_queue WHERE processed_at < $1`, time.Now().Add(-keep))
+	if err != nil {
+		return 0, err
+	}
+	return int(ct.RowsAffected()), nil
+}
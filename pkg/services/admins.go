@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+)
+
+// Роли перечитываются из БД не чаще раза в adminsTTL: проверка идёт на каждом апдейте
+const adminsTTL = 30 * time.Second
+
+var roleRank = map[models.AdminRole]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+// Allows — хватает ли роли have для действия, требующего need
+func Allows(have, need models.AdminRole) bool {
+	return roleRank[have] > 0 && roleRank[have] >= roleRank[need]
+}
+
+type adminCache struct {
+	mu     sync.RWMutex
+	roles  map[int64]models.AdminRole
+	loaded time.Time
+}
+
+// AdminRole возвращает роль пользователя или "" для не-админа.
+// Если БД недоступна, отдаём последнее известное значение.
+func (s *Service) AdminRole(ctx context.Context, userID int64) models.AdminRole {
+	s.admins.mu.RLock()
+	role, fresh := s.admins.roles[userID], time.Since(s.admins.loaded) < adminsTTL
+	s.admins.mu.RUnlock()
+	if fresh {
+		return role
+	}
+
+	list, err := s.Repo.GetAdmins(ctx)
+	if err != nil {
+		log.Println("GetAdmins:", err)
+		return role
+	}
+	roles := make(map[int64]models.AdminRole, len(list))
+	for _, a := range list {
+		roles[a.UserID] = a.Role
+	}
+	s.admins.mu.Lock()
+	s.admins.roles, s.admins.loaded = roles, time.Now()
+	s.admins.mu.Unlock()
+	return roles[userID]
+}
+
+// InvalidateAdmins — после изменения списка админов перечитать его сразу
+func (s *Service) InvalidateAdmins() {
+	s.admins.mu.Lock()
+	s.admins.loaded = time.Time{}
+	s.admins.mu.Unlock()
+}
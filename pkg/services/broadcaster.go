@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	broadcastBatch    = 100
+	broadcastIdle     = 10 * time.Second // как часто ищем рассылки без Wake
+	broadcastProgress = 30 * time.Second // как часто шлём автору прогресс
+	// аренда пачки получателей: пачка уходит в фоновой полосе Sender, с запасом на 429
+	broadcastLease = 5 * time.Minute
+)
+
+// Broadcaster рассылает запущенные рассылки через Sender (с общим лимитом).
+// Статус каждого получателя хранится в БД, поэтому после рестарта рассылка
+// продолжается с того места, где остановилась. Получателей экземпляр берёт в аренду
+// (ClaimDeliveries), поэтому при нескольких экземплярах бота сообщение уходит один раз.
+type Broadcaster struct {
+	repo   *repositories.Repository
+	sender *Sender
+	wake   chan struct{}
+}
+
+func NewBroadcaster(repo *repositories.Repository, sender *Sender) *Broadcaster {
+	return &Broadcaster{repo: repo, sender: sender, wake: make(chan struct{}, 1)}
+}
+
+// Wake — запущена новая рассылка, не ждать следующего опроса
+func (b *Broadcaster) Wake() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *Broadcaster) Run(ctx context.Context) {
+	// рассылки не должны задерживать ответы пользователям
+	ctx = WithPriority(ctx, PriorityBulk)
+	t := time.NewTicker(broadcastIdle)
+	defer t.Stop()
+	for {
+		b.runPending(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-b.wake:
+		}
+	}
+}
+
+func (b *Broadcaster) runPending(ctx context.Context) {
+	dbctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	list, err := b.repo.GetRunningBroadcasts(dbctx)
+	cancel()
+	if err != nil {
+		log.Println("GetRunningBroadcasts:", err)
+		return
+	}
+	for _, bc := range list {
+		b.process(ctx, bc)
+	}
+}
+
+func (b *Broadcaster) process(ctx context.Context, bc models.Broadcast) {
+	lastReport := time.Now()
+	for ctx.Err() == nil {
+		dbctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		// Рассылку могли отменить кнопкой
+		cur, err := b.repo.GetBroadcast(dbctx, bc.ID)
+		if err == nil && cur.Status != models.BroadcastRunning {
+			cancel()
+			return
+		}
+		users, err := b.repo.ClaimDeliveries(dbctx, bc.ID, broadcastBatch, broadcastLease)
+		cancel()
+		if err != nil {
+			log.Println("ClaimDeliveries:", err)
+			return
+		}
+		if len(users) == 0 {
+			b.finish(ctx, bc)
+			return
+		}
+
+		for i, userID := range users {
+			_, err := b.sender.Send(ctx, b.message(bc, userID))
+			var tgErr *tgbotapi.Error
+			if err != nil && (!errors.As(err, &tgErr) || tgErr.Code == 429) {
+				// Лимитер/сеть/остановка/долгий flood wait — необработанные получатели
+				// снова pending, продолжим позже (или другой экземпляр)
+				log.Printf("broadcast %d: %v", bc.ID, err)
+				b.release(ctx, bc, users[i:])
+				return
+			}
+			errText := ""
+			if err != nil {
+				errText = tgErr.Message
+			}
+			dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			if err := b.repo.MarkDelivery(dbctx, bc.ID, userID, errText); err != nil {
+				log.Println("MarkDelivery:", err)
+			}
+			cancel()
+		}
+
+		if time.Since(lastReport) >= broadcastProgress {
+			lastReport = time.Now()
+			b.report(ctx, bc, "📣 Рассылка #%d: отправлено %d из %d, ошибок %d")
+		}
+	}
+}
+
+func (b *Broadcaster) message(bc models.Broadcast, userID int64) tgbotapi.Chattable {
+	if bc.Text == "" {
+		return tgbotapi.NewCopyMessage(userID, bc.FromChatID, bc.MessageID)
+	}
+	msg := tgbotapi.NewMessage(userID, bc.Text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	if len(bc.ReplyMarkup) > 0 {
+		var mk tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal(bc.ReplyMarkup, &mk); err == nil {
+			msg.ReplyMarkup = mk
+		}
+	}
+	return msg
+}
+
+func (b *Broadcaster) finish(ctx context.Context, bc models.Broadcast) {
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	done, err := b.repo.FinishBroadcast(dbctx, bc.ID)
+	if err != nil {
+		log.Println("FinishBroadcast:", err)
+		return
+	}
+	if !done {
+		return
+	}
+	b.report(ctx, bc, "✅ Рассылка #%d завершена: доставлено %d из %d, ошибок %d")
+}
+
+func (b *Broadcaster) release(ctx context.Context, bc models.Broadcast, users []int64) {
+	// и при остановке: иначе получатели ждали бы конца аренды
+	dbctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+	defer cancel()
+	if err := b.repo.ReleaseDeliveries(dbctx, bc.ID, users); err != nil {
+		log.Println("ReleaseDeliveries:", err)
+	}
+}
+
+// report шлёт автору рассылки счётчики; format — id, sent, total, failed
+func (b *Broadcaster) report(ctx context.Context, bc models.Broadcast, format string) {
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	st, err := b.repo.GetBroadcastStats(dbctx, bc.ID)
+	cancel()
+	if err != nil {
+		log.Println("GetBroadcastStats:", err)
+		return
+	}
+	log.Printf("broadcast %d: sent=%d failed=%d pending=%d total=%d", bc.ID, st.Sent, st.Failed, st.Pending, st.Total)
+	if bc.CreatedBy == nil {
+		return
+	}
+	msg := tgbotapi.NewMessage(*bc.CreatedBy, fmt.Sprintf(format, bc.ID, st.Sent, st.Total, st.Failed))
+	if st.Pending > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏹ Остановить", fmt.Sprintf("bccancel_%d", bc.ID))))
+	}
+	if _, err := b.sender.Send(ctx, msg); err != nil {
+		log.Println("broadcast report:", err)
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
+)
+
+type CampaignStatus int
+
+const (
+	CampaignDraft     CampaignStatus = iota // даты не заданы
+	CampaignScheduled                       // ещё не началась
+	CampaignRunning
+	CampaignEnded
+)
+
+func StatusOf(c models.Campaign, now time.Time) CampaignStatus {
+	switch {
+	case c.StartsAt == nil:
+		return CampaignDraft
+	case now.Before(*c.StartsAt):
+		return CampaignScheduled
+	case c.EndsAt != nil && !now.Before(*c.EndsAt):
+		return CampaignEnded
+	default:
+		return CampaignRunning
+	}
+}
+
+func (s CampaignStatus) String() string {
+	switch s {
+	case CampaignScheduled:
+		return "запланирована"
+	case CampaignRunning:
+		return "идёт"
+	case CampaignEnded:
+		return "завершена"
+	default:
+		return "черновик"
+	}
+}
+
+// ResolveCampaign находит кампанию, о которой стоит говорить пользователю:
+// идущую, иначе ближайшую будущую, иначе последнюю завершённую.
+// repositories.ErrNoCampaign — кампаний нет совсем.
+func (s *Service) ResolveCampaign(ctx context.Context) (models.Campaign, CampaignStatus, error) {
+	c, err := s.Repo.GetCurrentCampaign(ctx)
+	if err == nil {
+		return c, CampaignRunning, nil
+	}
+	if !errors.Is(err, repositories.ErrNoCampaign) {
+		return c, 0, err
+	}
+
+	c, err = s.Repo.GetNextCampaign(ctx)
+	if err == nil {
+		return c, CampaignScheduled, nil
+	}
+	if !errors.Is(err, repositories.ErrNoCampaign) {
+		return c, 0, err
+	}
+
+	c, err = s.Repo.GetLastEndedCampaign(ctx)
+	if err != nil {
+		return c, 0, err
+	}
+	return c, CampaignEnded, nil
+}
+
+// PrizeCampaign — кампания, выигрыш в которой показывает /mypack: идущая,
+// иначе последняя завершённая (будущая ещё ничего не разыграла).
+// repositories.ErrNoCampaign — таких нет.
+func (s *Service) PrizeCampaign(ctx context.Context) (models.Campaign, error) {
+	c, err := s.Repo.GetCurrentCampaign(ctx)
+	if !errors.Is(err, repositories.ErrNoCampaign) {
+		return c, err
+	}
+	return s.Repo.GetLastEndedCampaign(ctx)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Лимиты Telegram на один чат: в личку ~1 сообщение в секунду, в группу — 20 в минуту
+const (
+	privateChatRate = rate.Limit(1)
+	groupChatRate   = rate.Limit(20.0 / 60)
+	// полную корзину, к которой не обращались дольше, можно выбросить
+	chatLimiterIdle  = time.Minute
+	chatLimiterSweep = time.Minute
+	// дольше ждать свою очередь в одном чате не имеет смысла
+	maxChatWait = 30 * time.Second
+)
+
+type chatBucket struct {
+	lim         *rate.Limiter
+	used        time.Time
+	pausedUntil time.Time // retry_after последнего 429 в этот чат
+}
+
+// chatLimiter — корзины токенов по чатам. Простаивающие удаляются лениво,
+// при очередном обращении, не чаще раза в chatLimiterSweep.
+type chatLimiter struct {
+	mu        sync.Mutex
+	buckets   map[int64]*chatBucket
+	lastSweep time.Time
+}
+
+func newChatLimiter() *chatLimiter {
+	return &chatLimiter{buckets: map[int64]*chatBucket{}, lastSweep: time.Now()}
+}
+
+// Wait ждёт, пока в чат chatID можно отправить сообщение: конец паузы после 429
+// и свой токен; 0 — чат неизвестен, не ждём
+func (c *chatLimiter) Wait(ctx context.Context, chatID int64) error {
+	if chatID == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, maxChatWait)
+	defer cancel()
+	lim, pausedUntil := c.bucket(chatID)
+	if pause := time.Until(pausedUntil); pause > 0 {
+		t := time.NewTimer(pause)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return lim.Wait(ctx)
+}
+
+// Pause — Telegram ответил 429 на сообщение в чат: до retry_after в него не пишем
+func (c *chatLimiter) Pause(chatID int64, retryAfter time.Duration) {
+	if chatID == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := c.get(chatID)
+	if until := time.Now().Add(retryAfter); until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
+
+// bucket — корзина чата и конец его паузы после 429
+func (c *chatLimiter) bucket(chatID int64) (*rate.Limiter, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := c.get(chatID)
+	return b.lim, b.pausedUntil
+}
+
+// get находит или создаёт корзину чата; вызывается под mu
+func (c *chatLimiter) get(chatID int64) *chatBucket {
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= chatLimiterSweep {
+		c.lastSweep = now
+		for id, b := range c.buckets {
+			// неполная корзина или пауза — кто-то ещё ждёт своей очереди
+			if now.Sub(b.used) >= chatLimiterIdle && b.lim.TokensAt(now) >= 1 && !now.Before(b.pausedUntil) {
+				delete(c.buckets, id)
+			}
+		}
+	}
+
+	b, ok := c.buckets[chatID]
+	if !ok {
+		// у пользователей ID положительные, у групп и каналов — отрицательные
+		r := privateChatRate
+		if chatID < 0 {
+			r = groupChatRate
+		}
+		b = &chatBucket{lim: rate.NewLimiter(r, 1)}
+		c.buckets[chatID] = b
+	}
+	b.used = now
+	return b
+}
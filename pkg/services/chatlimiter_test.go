@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChatLimiterRates(t *testing.T) {
+	c := newChatLimiter()
+	if lim, _ := c.bucket(42); lim.Limit() != privateChatRate {
+		t.Errorf("private chat: limit %v, want %v", lim.Limit(), privateChatRate)
+	}
+	if lim, _ := c.bucket(-100500); lim.Limit() != groupChatRate {
+		t.Errorf("group chat: limit %v, want %v", lim.Limit(), groupChatRate)
+	}
+	if lim, _ := c.bucket(42); lim.Burst() != 1 {
+		t.Errorf("burst %d, want 1", lim.Burst())
+	}
+}
+
+func TestChatLimiterSweepsIdleBuckets(t *testing.T) {
+	c := newChatLimiter()
+	c.bucket(1)
+	busy, _ := c.bucket(-2)
+	busy.Allow() // корзина группы пуста ещё ~3 с
+	c.bucket(3)
+	c.Pause(3, time.Hour)
+
+	// все три давно не трогали, пора чистить
+	old := time.Now().Add(-2 * chatLimiterIdle)
+	c.mu.Lock()
+	for _, b := range c.buckets {
+		b.used = old
+	}
+	c.lastSweep = old
+	c.mu.Unlock()
+
+	c.bucket(4) // обращение к любому чату запускает чистку
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.buckets[1]; ok {
+		t.Error("idle full bucket was not evicted")
+	}
+	if _, ok := c.buckets[-2]; !ok {
+		t.Error("bucket without a token was evicted")
+	}
+	if _, ok := c.buckets[3]; !ok {
+		t.Error("paused bucket was evicted")
+	}
+}
+
+func TestChatLimiterPauseIsPerChat(t *testing.T) {
+	c := newChatLimiter()
+	const pause = 200 * time.Millisecond
+	c.Pause(1, pause)
+
+	start := time.Now()
+	if err := c.Wait(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d >= pause {
+		t.Errorf("other chat waited %s for a paused chat", d)
+	}
+
+	start = time.Now()
+	if err := c.Wait(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < pause-10*time.Millisecond {
+		t.Errorf("paused chat waited %s, want about %s", d, pause)
+	}
+}
+
+func TestChatLimiterPauseRespectsContext(t *testing.T) {
+	c := newChatLimiter()
+	c.Pause(1, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Wait(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, want deadline exceeded", err)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// ParseCodes разбирает загруженный админом файл с кодами по имени файла: .csv (код —
+// первая колонка, разделитель «,» или «;»), остальные — как .txt (код на строку целиком,
+// с запятыми и кавычками). Пустые строки, заголовок и повторы внутри файла отбрасываются,
+// порядок сохраняется.
+func ParseCodes(name string, data []byte) ([]string, error) {
+	var lines []string
+	if strings.EqualFold(path.Ext(name), ".csv") {
+		r := newCSVReader(data)
+		for {
+			rec, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(rec) > 0 {
+				lines = append(lines, rec[0])
+			}
+		}
+	} else {
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		lines = strings.Split(string(data), "\n")
+	}
+
+	seen := make(map[string]struct{})
+	var codes []string
+	for i, line := range lines {
+		code := strings.TrimSpace(line)
+		if code == "" {
+			continue
+		}
+		if i == 0 && isCodeHeader(code) {
+			continue
+		}
+		if _, dup := seen[code]; dup {
+			continue
+		}
+		seen[code] = struct{}{}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil, errors.New("в файле нет кодов")
+	}
+	return codes, nil
+}
+
+// newCSVReader — терпимый к Excel ридер: без BOM, разделитель «,» или «;» по первой строке
+func newCSVReader(data []byte) *csv.Reader {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM из Excel
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	if firstLine, _, _ := bytes.Cut(data, []byte("\n")); bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		r.Comma = ';'
+	}
+	return r
+}
+
+func isCodeHeader(s string) bool {
+	switch strings.ToLower(s) {
+	case "code", "codes", "код", "коды":
+		return true
+	}
+	return false
+}
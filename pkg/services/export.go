@@ -0,0 +1,152 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+)
+
+// ExportWriter пишет выгрузку построчно; Close дописывает файл
+type ExportWriter interface {
+	WriteRow(models.ExportRow) error
+	Close() error
+}
+
+var exportHeader = []string{"user_id", "username", "joined_at", "campaign", "pack", "claimed_at"}
+
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+func exportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(time.Local).Format(exportTimeLayout)
+}
+
+func exportRecord(r models.ExportRow) []string {
+	return []string{
+		strconv.FormatInt(r.UserID, 10), r.Username, exportTime(r.JoinedAt),
+		r.Campaign, r.PackName, exportTime(r.ClaimedAt),
+	}
+}
+
+type csvExport struct {
+	w *csv.Writer
+}
+
+// NewCSVExport — CSV с BOM, чтобы Excel открыл UTF-8 без вопросов
+func NewCSVExport(w io.Writer) (ExportWriter, error) {
+	if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportHeader); err != nil {
+		return nil, err
+	}
+	return &csvExport{w: cw}, nil
+}
+
+func (e *csvExport) WriteRow(r models.ExportRow) error {
+	return e.w.Write(exportRecord(r))
+}
+
+func (e *csvExport) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// Минимальный XLSX: один лист, строки inline, без стилей и sharedStrings
+var xlsxStatic = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="users" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+}
+
+type xlsxExport struct {
+	zw    *zip.Writer
+	sheet io.Writer
+}
+
+func NewXLSXExport(w io.Writer) (ExportWriter, error) {
+	zw := zip.NewWriter(w)
+	for _, f := range xlsxStatic {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return nil, err
+		}
+	}
+	// Лист пишется последним, потоком, пока открыт его элемент архива
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n"+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+	e := &xlsxExport{zw: zw, sheet: sheet}
+	if err := e.writeCells(exportHeader, false); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *xlsxExport) WriteRow(r models.ExportRow) error {
+	return e.writeCells(exportRecord(r), true)
+}
+
+// writeCells пишет строку; при numericID первая ячейка — число (user_id)
+func (e *xlsxExport) writeCells(cells []string, numericID bool) error {
+	if _, err := io.WriteString(e.sheet, "<row>"); err != nil {
+		return err
+	}
+	for i, v := range cells {
+		var err error
+		if i == 0 && numericID {
+			_, err = fmt.Fprintf(e.sheet, "<c><v>%s</v></c>", v)
+		} else {
+			if _, err = io.WriteString(e.sheet, `<c t="inlineStr"><is><t>`); err == nil {
+				if err = xml.EscapeText(e.sheet, []byte(v)); err == nil {
+					_, err = io.WriteString(e.sheet, "</t></is></c>")
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(e.sheet, "</row>")
+	return err
+}
+
+func (e *xlsxExport) Close() error {
+	if _, err := io.WriteString(e.sheet, "</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
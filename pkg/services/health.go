@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// цикл приёма апдейтов отмечается раз в несколько секунд; дольше — завис
+	maxLoopSilence = 30 * time.Second
+	// очередь воркеров в памяти заполнена почти целиком — новые апдейты копятся в БД
+	maxQueueFill = 0.9
+	// Telegram API отвечает только ошибками дольше этого — не готовы
+	maxAPIFailure = 2 * time.Minute
+)
+
+// Health отдаёт /healthz (процесс и цикл апдейтов живы) и /readyz
+// (плюс БД, очередь воркеров и Telegram API) для оркестратора
+type Health struct {
+	ping   func(ctx context.Context) error
+	sender *Sender
+	queue  func() (depth, capacity int)
+	beat   atomic.Int64
+}
+
+func NewHealth(ping func(ctx context.Context) error, sender *Sender, queue func() (depth, capacity int)) *Health {
+	h := &Health{ping: ping, sender: sender, queue: queue}
+	h.Beat()
+	return h
+}
+
+// Beat — цикл апдейтов жив
+func (h *Health) Beat() {
+	h.beat.Store(time.Now().UnixNano())
+}
+
+type healthReport struct {
+	Status          string     `json:"status"`
+	LoopAge         string     `json:"loop_age"`
+	DB              string     `json:"db,omitempty"`
+	QueueDepth      int        `json:"queue_depth"`
+	QueueCapacity   int        `json:"queue_capacity"`
+	TelegramLastOK  *time.Time `json:"telegram_last_ok,omitempty"`
+	TelegramLastErr *time.Time `json:"telegram_last_error,omitempty"`
+	Problems        []string   `json:"problems,omitempty"`
+}
+
+func (h *Health) report(ctx context.Context, ready bool) healthReport {
+	var rep healthReport
+	loopAge := time.Since(unixNano(h.beat.Load()))
+	rep.LoopAge = loopAge.Round(time.Millisecond).String()
+	if loopAge > maxLoopSilence {
+		rep.Problems = append(rep.Problems, "update loop is stuck")
+	}
+	rep.QueueDepth, rep.QueueCapacity = h.queue()
+
+	lastOK, lastErr := h.sender.LastSuccess(), h.sender.LastError()
+	if !lastOK.IsZero() {
+		rep.TelegramLastOK = &lastOK
+	}
+	if !lastErr.IsZero() {
+		rep.TelegramLastErr = &lastErr
+	}
+
+	if ready {
+		pingCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		rep.DB = "ok"
+		if err := h.ping(pingCtx); err != nil {
+			rep.DB = err.Error()
+			rep.Problems = append(rep.Problems, "database is unreachable")
+		}
+		if rep.QueueCapacity > 0 && float64(rep.QueueDepth) >= maxQueueFill*float64(rep.QueueCapacity) {
+			rep.Problems = append(rep.Problems, "worker queue is almost full")
+		}
+		if lastErr.After(lastOK) && time.Since(lastOK) > maxAPIFailure {
+			rep.Problems = append(rep.Problems, "telegram api calls are failing")
+		}
+	}
+
+	rep.Status = "ok"
+	if len(rep.Problems) > 0 {
+		rep.Status = "fail"
+	}
+	return rep
+}
+
+func (h *Health) serve(w http.ResponseWriter, r *http.Request, ready bool) {
+	rep := h.report(r.Context(), ready)
+	w.Header().Set("Content-Type", "application/json")
+	if rep.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(rep)
+}
+
+func (h *Health) Healthz(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, false)
+}
+
+func (h *Health) Readyz(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, true)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// Priority — полоса, в которой вызов ждёт общий лимит Telegram
+type Priority int
+
+const (
+	// PriorityInteractive — ответы пользователям и админам (по умолчанию)
+	PriorityInteractive Priority = iota
+	// PriorityBulk — рассылки, анонсы и прочая фоновая отправка
+	PriorityBulk
+	priorityCount
+)
+
+// Доли общего лимита, когда обе полосы заняты; свободную долю забирает другая полоса.
+// 9:1 — из 28 rps рассылке остаётся ~3, ответы идут почти без очереди.
+var laneWeights = [priorityCount]int{PriorityInteractive: 9, PriorityBulk: 1}
+
+var laneNames = [priorityCount]string{PriorityInteractive: "interactive", PriorityBulk: "bulk"}
+
+type priorityKey struct{}
+
+// WithPriority — все отправки с этим контекстом идут в полосе p
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p >= 0 && p < priorityCount {
+		return p
+	}
+	return PriorityInteractive
+}
+
+// lanes раздаёт токены общего лимитера очередям ожидающих по весам
+// (сглаженный weighted round robin); внутри полосы — по порядку прихода.
+type lanes struct {
+	mu     sync.Mutex
+	queues [priorityCount][]chan struct{}
+	credit [priorityCount]int
+	ready  chan struct{}
+}
+
+// newLanes запускает раздачу токенов lim; живёт, пока жив процесс
+func newLanes(lim *rate.Limiter) *lanes {
+	l := &lanes{ready: make(chan struct{}, 1)}
+	go l.run(lim)
+	return l
+}
+
+// wait ждёт токен в полосе p
+func (l *lanes) wait(ctx context.Context, p Priority) error {
+	ch := make(chan struct{})
+	l.mu.Lock()
+	l.queues[p] = append(l.queues[p], ch)
+	l.mu.Unlock()
+	select {
+	case l.ready <- struct{}{}:
+	default:
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		if i := slices.Index(l.queues[p], ch); i >= 0 {
+			l.queues[p] = slices.Delete(l.queues[p], i, i+1)
+		}
+		// если токен уже выдан, он пропадает — это дешевле, чем отдавать его обратно
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (l *lanes) run(lim *rate.Limiter) {
+	for {
+		if !l.pending() {
+			<-l.ready
+			continue
+		}
+		// Полосу выбираем, когда токен уже есть: за время ожидания мог прийти
+		// более срочный вызов
+		_ = lim.Wait(context.Background())
+		if ch := l.pick(); ch != nil {
+			close(ch)
+		}
+	}
+}
+
+func (l *lanes) pending() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, q := range l.queues {
+		if len(q) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *lanes) pick() chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	best, total := -1, 0
+	for i, q := range l.queues {
+		if len(q) == 0 {
+			l.credit[i] = 0
+			continue
+		}
+		l.credit[i] += laneWeights[i]
+		total += laneWeights[i]
+		if best < 0 || l.credit[i] > l.credit[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	l.credit[best] -= total
+	ch := l.queues[best][0]
+	l.queues[best] = l.queues[best][1:]
+	return ch
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestLanesWeights(t *testing.T) {
+	l := &lanes{ready: make(chan struct{}, 1)}
+	const n = 100
+	owner := map[chan struct{}]Priority{}
+	for p := PriorityInteractive; p < priorityCount; p++ {
+		for i := 0; i < n; i++ {
+			ch := make(chan struct{})
+			owner[ch] = p
+			l.queues[p] = append(l.queues[p], ch)
+		}
+	}
+
+	// пока заняты обе полосы — 9:1
+	var got [priorityCount]int
+	for i := 0; i < 100; i++ {
+		got[owner[l.pick()]]++
+	}
+	if got[PriorityInteractive] != 90 || got[PriorityBulk] != 10 {
+		t.Fatalf("picked %v, want [90 10]", got)
+	}
+
+	// свободная доля уходит другой полосе
+	l.queues[PriorityInteractive] = nil
+	for i := 0; i < 10; i++ {
+		if p := owner[l.pick()]; p != PriorityBulk {
+			t.Fatalf("pick %d went to lane %d with only bulk waiting", i, p)
+		}
+	}
+}
+
+func TestLanesKeepOrderWithinLane(t *testing.T) {
+	l := &lanes{ready: make(chan struct{}, 1)}
+	var chans []chan struct{}
+	for i := 0; i < 5; i++ {
+		ch := make(chan struct{})
+		chans = append(chans, ch)
+		l.queues[PriorityBulk] = append(l.queues[PriorityBulk], ch)
+	}
+	for i, want := range chans {
+		if got := l.pick(); got != want {
+			t.Fatalf("pick %d out of order", i)
+		}
+	}
+	if l.pick() != nil {
+		t.Fatal("pick from empty lanes returned a waiter")
+	}
+}
+
+func TestLanesCanceledWaiterLeavesQueue(t *testing.T) {
+	// без раздачи: токенов нет, отменённый ждёт до конца контекста
+	l := &lanes{ready: make(chan struct{}, 1)}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx, PriorityInteractive); err == nil {
+		t.Fatal("wait without tokens succeeded")
+	}
+	if l.pending() {
+		t.Fatal("canceled waiter is still queued")
+	}
+}
+
+func TestLanesCanceledWaiterDoesNotBlockOthers(t *testing.T) {
+	// один токен в секунду: первый уходит сразу, следующий — через секунду
+	lim := rate.NewLimiter(1, 1)
+	lim.Allow()
+	l := newLanes(lim)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() { canceled <- l.wait(ctx, PriorityInteractive) }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-canceled; err == nil {
+		t.Fatal("canceled waiter got a token")
+	}
+
+	// токен, который мог достаться отменённому, пропадает, но следующий ждущий
+	// получает свой не позже чем через пару интервалов лимитера
+	wctx, wcancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer wcancel()
+	if err := l.wait(wctx, PriorityBulk); err != nil {
+		t.Fatalf("waiter after a canceled one: %v", err)
+	}
+}
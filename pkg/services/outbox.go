@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	outboxPoll  = 500 * time.Millisecond // точность задержек между сообщениями
+	outboxBatch = 100
+	// аренда сообщения на время отправки: с запасом на ожидание лимитов Sender и повторы
+	outboxLease       = 2 * time.Minute
+	outboxMaxAttempts = 20
+	outboxMaxBackoff  = 10 * time.Minute
+	outboxKeep        = 7 * 24 * time.Hour
+	outboxPurgeEvery  = time.Hour
+)
+
+// NewOutboxMessage готовит текстовое сообщение к отправке не раньше чем через delay
+func NewOutboxMessage(msg tgbotapi.MessageConfig, delay time.Duration) (models.OutboxMessage, error) {
+	m := models.OutboxMessage{
+		ChatID:    msg.ChatID,
+		Text:      msg.Text,
+		ParseMode: msg.ParseMode,
+		Delay:     delay,
+	}
+	if msg.ReplyMarkup != nil {
+		mk, err := json.Marshal(msg.ReplyMarkup)
+		if err != nil {
+			return models.OutboxMessage{}, err
+		}
+		m.ReplyMarkup = mk
+	}
+	return m, nil
+}
+
+// Outbox отправляет сообщения из outbox через Sender, когда подходит их время.
+// Неудачные попытки повторяются с растущей паузой; сообщения одного чата уходят
+// строго по порядку. Сообщение перед отправкой арендуется (ClaimDueOutbox), поэтому
+// при нескольких экземплярах бота его отправляет только один.
+type Outbox struct {
+	repo   *repositories.Repository
+	sender *Sender
+}
+
+func NewOutbox(repo *repositories.Repository, sender *Sender) *Outbox {
+	return &Outbox{repo: repo, sender: sender}
+}
+
+func (o *Outbox) Run(ctx context.Context) {
+	t := time.NewTicker(outboxPoll)
+	defer t.Stop()
+	lastPurge := time.Time{}
+	for {
+		o.dispatch(ctx)
+		if time.Since(lastPurge) >= outboxPurgeEvery {
+			lastPurge = time.Now()
+			o.purge(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (o *Outbox) dispatch(ctx context.Context) {
+	dbctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	list, err := o.repo.ClaimDueOutbox(dbctx, outboxLease, outboxBatch)
+	cancel()
+	if err != nil {
+		log.Println("ClaimDueOutbox:", err)
+		return
+	}
+	// в пачке по одному сообщению на чат: шлём параллельно, темп держит Sender,
+	// и вся пачка укладывается в аренду
+	var wg sync.WaitGroup
+	for _, m := range list {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.send(ctx, m)
+		}()
+	}
+	wg.Wait()
+}
+
+func (o *Outbox) send(ctx context.Context, m models.OutboxMessage) {
+	_, err := o.sender.Send(ctx, o.message(m))
+
+	// итог записываем и при остановке, иначе отправленное уйдёт повторно
+	dbctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+	defer cancel()
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// остановка — сообщение уйдёт после рестарта или с другого экземпляра
+		err = o.repo.ReleaseOutbox(dbctx, m.ID)
+	case err == nil:
+		err = o.repo.MarkOutboxSent(dbctx, m.ID)
+	case permanentSendError(err) || m.Attempts+1 >= outboxMaxAttempts:
+		log.Printf("outbox %d to %d: giving up: %v", m.ID, m.ChatID, err)
+		err = o.repo.FailOutbox(dbctx, m.ID, err.Error())
+	default:
+		log.Printf("outbox %d to %d: %v", m.ID, m.ChatID, err)
+		err = o.repo.RetryOutbox(dbctx, m.ID, err.Error(), outboxBackoff(m.Attempts))
+	}
+	if err != nil {
+		log.Println("outbox:", err)
+	}
+}
+
+func (o *Outbox) message(m models.OutboxMessage) tgbotapi.MessageConfig {
+	msg := tgbotapi.NewMessage(m.ChatID, m.Text)
+	msg.ParseMode = m.ParseMode
+	if len(m.ReplyMarkup) > 0 {
+		var mk tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal(m.ReplyMarkup, &mk); err == nil {
+			msg.ReplyMarkup = mk
+		}
+	}
+	return msg
+}
+
+func (o *Outbox) purge(ctx context.Context) {
+	dbctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	n, err := o.repo.PurgeSentOutbox(dbctx, outboxKeep)
+	if err != nil {
+		log.Println("PurgeSentOutbox:", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("outbox: purged %d sent messages", n)
+	}
+}
+
+// outboxBackoff — 5 с, 10 с, 20 с … до outboxMaxBackoff
+func outboxBackoff(attempts int) time.Duration {
+	d := 5 * time.Second
+	for i := 0; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, outboxMaxBackoff)
+}
+
+// permanentSendError — Telegram отказал окончательно (чат недоступен, кривой запрос);
+// 429 и 5xx, как и сетевые ошибки, стоит повторить
+func permanentSendError(err error) bool {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) {
+		return false
+	}
+	return tgErr.Code >= 400 && tgErr.Code < 500 && tgErr.Code != 429
+}
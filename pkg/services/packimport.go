@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+)
+
+// PackImportRow — строка файла импорта; Problem != "" — строка не будет импортирована
+type PackImportRow struct {
+	Line    int // номер строки CSV или элемента JSON, с 1
+	Pack    models.PackImport
+	Problem string
+}
+
+// Колонки CSV без заголовка идут в этом порядке
+var packImportColumns = []string{"name", "url", "weight", "stock"}
+
+// ParsePackImport разбирает файл импорта призов: JSON-массив объектов
+// {name, url, weight, stock} или CSV с колонками name, url, weight, stock
+// (заголовок необязателен). Вес по умолчанию 1, пустой остаток или «-» — без ограничения.
+// Ошибка — только если файл не читается целиком; проблемы строк — в Problem.
+func ParsePackImport(data []byte) ([]PackImportRow, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	var rows []PackImportRow
+	var err error
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		rows, err = parsePackImportJSON(trimmed)
+	} else {
+		rows, err = parsePackImportCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("в файле нет строк")
+	}
+	return rows, nil
+}
+
+func parsePackImportJSON(data []byte) ([]PackImportRow, error) {
+	var items []struct {
+		Name   string          `json:"name"`
+		URL    string          `json:"url"`
+		Weight *int            `json:"weight"`
+		Stock  json.RawMessage `json:"stock"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	rows := make([]PackImportRow, 0, len(items))
+	for i, it := range items {
+		row := PackImportRow{Line: i + 1, Pack: models.PackImport{
+			Name: strings.TrimSpace(it.Name), URL: strings.TrimSpace(it.URL), Weight: 1,
+		}}
+		if it.Weight != nil {
+			row.Pack.Weight = *it.Weight
+		}
+		if stock := strings.Trim(string(it.Stock), `"`); stock != "" && stock != "null" {
+			row.Pack.Stock, row.Problem = parseImportStock(stock)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parsePackImportCSV(data []byte) ([]PackImportRow, error) {
+	r := newCSVReader(data)
+	cols := packImportColumns
+	var rows []PackImportRow
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && isPackImportHeader(rec) {
+			cols = make([]string, len(rec))
+			for i, c := range rec {
+				cols[i] = strings.ToLower(strings.TrimSpace(c))
+			}
+			continue
+		}
+		fields := make(map[string]string, len(cols))
+		empty := true
+		for i, c := range cols {
+			if i < len(rec) {
+				fields[c] = strings.TrimSpace(rec[i])
+				empty = empty && fields[c] == ""
+			}
+		}
+		if empty {
+			continue
+		}
+
+		row := PackImportRow{Line: line, Pack: models.PackImport{Name: fields["name"], URL: fields["url"], Weight: 1}}
+		if w := fields["weight"]; w != "" {
+			n, err := strconv.Atoi(w)
+			if err != nil {
+				row.Problem = fmt.Sprintf("вес %q — не число", w)
+			}
+			row.Pack.Weight = n
+		}
+		if s := fields["stock"]; s != "" && s != "-" && row.Problem == "" {
+			row.Pack.Stock, row.Problem = parseImportStock(s)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseImportStock(s string) (*int, string) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Sprintf("остаток %q — не число", s)
+	}
+	return &n, ""
+}
+
+func isPackImportHeader(rec []string) bool {
+	return len(rec) > 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "name")
+}
+
+// ValidatePackImport отмечает строки, которые нельзя импортировать: пустые поля,
+// плохие ссылки, отрицательные числа и имена, уже занятые в кампании или в самом файле
+// (UNIQUE(campaign_id, name)).
+func ValidatePackImport(rows []PackImportRow, existing []string) {
+	taken := make(map[string]int, len(existing)) // имя → строка файла, 0 — уже в кампании
+	for _, name := range existing {
+		taken[name] = 0
+	}
+	for i := range rows {
+		row := &rows[i]
+		if row.Problem != "" {
+			continue
+		}
+		p := row.Pack
+		switch {
+		case p.Name == "":
+			row.Problem = "нет названия"
+		case !validPackURL(p.URL):
+			row.Problem = fmt.Sprintf("некорректная ссылка %q", p.URL)
+		case p.Weight < 0:
+			row.Problem = "отрицательный вес"
+		case p.Stock != nil && *p.Stock < 0:
+			row.Problem = "отрицательный остаток"
+		}
+		if row.Problem != "" {
+			continue
+		}
+		if line, dup := taken[p.Name]; dup {
+			if line == 0 {
+				row.Problem = fmt.Sprintf("«%s» уже есть в кампании", p.Name)
+			} else {
+				row.Problem = fmt.Sprintf("«%s» повторяет строку %d", p.Name, line)
+			}
+			continue
+		}
+		taken[p.Name] = row.Line
+	}
+}
+
+func validPackURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// WorkerPool обрабатывает апдейты параллельно, но апдейты одного чата — строго
+// по очереди и в порядке поступления: у каждого чата своя очередь и не больше одной
+// горутины, которая её разбирает. Так диалоги админов и повторные нажатия «Испытать удачу»
+// не обгоняют друг друга, а медленный или заспамленный чат не задерживает остальные.
+type WorkerPool struct {
+	handle  func(tgbotapi.Update)
+	workers chan struct{} // одновременно обрабатываемые апдейты
+	slots   chan struct{} // принятые, но ещё не обработанные апдейты
+
+	mu    sync.Mutex
+	chats map[int64][]tgbotapi.Update // очередь чата; ключ есть — горутина чата работает
+	wg    sync.WaitGroup
+	stop  atomic.Bool // остановка: очереди чатов больше не разбираем
+}
+
+// NewWorkerPool — не больше workers обработчиков одновременно и не больше maxPending
+// апдейтов внутри пула; сверх этого апдейты ждут в jobs (и дальше — в БД)
+func NewWorkerPool(workers, maxPending int, handle func(tgbotapi.Update)) *WorkerPool {
+	return &WorkerPool{
+		handle:  handle,
+		workers: make(chan struct{}, workers),
+		slots:   make(chan struct{}, maxPending),
+		chats:   map[int64][]tgbotapi.Update{},
+	}
+}
+
+// Run раскладывает апдейты по очередям чатов, пока jobs не закрыт или ctx не отменён,
+// затем ждёт, пока чаты доделают начатое. Run не ждёт отдельный чат — только общий
+// лимит maxPending. При отмене ещё не начатые апдейты не обрабатываются: они остаются в БД.
+func (p *WorkerPool) Run(ctx context.Context, jobs <-chan tgbotapi.Update) {
+	defer func() {
+		if ctx.Err() != nil {
+			p.stop.Store(true)
+		}
+		p.wg.Wait()
+	}()
+	for {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case upd, ok := <-jobs:
+			if !ok {
+				return
+			}
+			p.enqueue(upd)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *WorkerPool) enqueue(upd tgbotapi.Update) {
+	key := chatKey(upd)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	q, active := p.chats[key]
+	p.chats[key] = append(q, upd)
+	if !active {
+		p.wg.Add(1)
+		go p.runChat(key)
+	}
+}
+
+// runChat разбирает очередь чата по одному апдейту и завершается, когда она пуста
+func (p *WorkerPool) runChat(key int64) {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		q := p.chats[key]
+		if len(q) == 0 || p.stop.Load() {
+			delete(p.chats, key)
+			p.mu.Unlock()
+			return
+		}
+		upd := q[0]
+		if len(q) == 1 {
+			p.chats[key] = q[:0]
+		} else {
+			p.chats[key] = q[1:]
+		}
+		p.mu.Unlock()
+
+		p.workers <- struct{}{}
+		if !p.stop.Load() {
+			p.safeHandle(upd)
+		}
+		<-p.workers
+		<-p.slots
+	}
+}
+
+// safeHandle — паника в обработчике не должна останавливать очередь чата
+func (p *WorkerPool) safeHandle(upd tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("panic in update %d: %v\n%s", upd.UpdateID, r, debug.Stack())
+		}
+	}()
+	p.handle(upd)
+}
+
+// chatKey — чат апдейта; у коллбэков из inline-сообщений чата нет, тогда пользователь.
+// Апдейты без чата и пользователя ни с чем не упорядочиваем.
+func chatKey(upd tgbotapi.Update) int64 {
+	switch {
+	case upd.CallbackQuery != nil:
+		if upd.CallbackQuery.Message != nil && upd.CallbackQuery.Message.Chat != nil {
+			return upd.CallbackQuery.Message.Chat.ID
+		}
+		return upd.CallbackQuery.From.ID
+	case upd.FromChat() != nil:
+		return upd.FromChat().ID
+	case upd.SentFrom() != nil:
+		return upd.SentFrom().ID
+	default:
+		return int64(upd.UpdateID)
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func messageUpdate(updateID int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: updateID,
+		Message:  &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}, From: &tgbotapi.User{ID: chatID}},
+	}
+}
+
+func callbackUpdate(updateID int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: updateID,
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			From:    &tgbotapi.User{ID: chatID},
+			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}},
+		},
+	}
+}
+
+func TestWorkerPoolKeepsOrderWithinChat(t *testing.T) {
+	const chats, perChat = 50, 40
+
+	var mu sync.Mutex
+	seen := map[int64][]int{}
+	var running [chats]atomic.Int32
+	var overlap atomic.Bool
+
+	pool := NewWorkerPool(8, 4, func(upd tgbotapi.Update) {
+		chatID := chatKey(upd)
+		if running[chatID].Add(1) > 1 {
+			overlap.Store(true)
+		}
+		time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+		mu.Lock()
+		seen[chatID] = append(seen[chatID], upd.UpdateID)
+		mu.Unlock()
+		running[chatID].Add(-1)
+	})
+
+	jobs := make(chan tgbotapi.Update)
+	done := make(chan struct{})
+	go func() {
+		pool.Run(context.Background(), jobs)
+		close(done)
+	}()
+	// апдейты разных чатов вперемешку, сообщения и коллбэки
+	id := 0
+	for i := 0; i < perChat; i++ {
+		for c := int64(0); c < chats; c++ {
+			id++
+			if id%3 == 0 {
+				jobs <- callbackUpdate(id, c)
+			} else {
+				jobs <- messageUpdate(id, c)
+			}
+		}
+	}
+	close(jobs)
+	<-done
+
+	if overlap.Load() {
+		t.Error("updates of one chat were handled concurrently")
+	}
+	for c := int64(0); c < chats; c++ {
+		ids := seen[c]
+		if len(ids) != perChat {
+			t.Fatalf("chat %d: handled %d updates, want %d", c, len(ids), perChat)
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				t.Fatalf("chat %d: update %d handled after %d", c, ids[i], ids[i-1])
+			}
+		}
+	}
+}
+
+func TestWorkerPoolBusyChatDoesNotBlockOthers(t *testing.T) {
+	const backlog = 100 // очередь чата A намного длиннее числа воркеров
+	chatA, chatB := int64(1), int64(2)
+
+	release := make(chan struct{})
+	handledB := make(chan struct{})
+	var handledA atomic.Int32
+	pool := NewWorkerPool(4, 1024, func(upd tgbotapi.Update) {
+		if chatKey(upd) == chatA {
+			<-release
+			handledA.Add(1)
+			return
+		}
+		close(handledB)
+	})
+
+	jobs := make(chan tgbotapi.Update)
+	done := make(chan struct{})
+	go func() {
+		pool.Run(context.Background(), jobs)
+		close(done)
+	}()
+	// первый апдейт чата A висит в обработчике, остальные копятся в его очереди
+	for i := 1; i <= backlog; i++ {
+		jobs <- messageUpdate(i, chatA)
+	}
+	jobs <- messageUpdate(backlog+1, chatB)
+
+	select {
+	case <-handledB:
+	case <-time.After(2 * time.Second):
+		t.Fatal("a busy chat blocked another chat")
+	}
+	close(release)
+	close(jobs)
+	<-done
+	if n := handledA.Load(); n != backlog {
+		t.Fatalf("chat A: handled %d updates, want %d", n, backlog)
+	}
+}
+
+func TestWorkerPoolStopSkipsPending(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var handled atomic.Int32
+	pool := NewWorkerPool(1, 16, func(upd tgbotapi.Update) {
+		if handled.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jobs := make(chan tgbotapi.Update, 3)
+	jobs <- messageUpdate(1, 7)
+	jobs <- messageUpdate(2, 7)
+	jobs <- messageUpdate(3, 7)
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx, jobs)
+		close(done)
+	}()
+
+	<-started
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("Run returned before the running handler finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	if n := handled.Load(); n != 1 {
+		t.Fatalf("handled %d updates after stop, want 1", n)
+	}
+}
+
+func TestWorkerPoolSurvivesPanic(t *testing.T) {
+	var handled []int
+	pool := NewWorkerPool(1, 1, func(upd tgbotapi.Update) {
+		handled = append(handled, upd.UpdateID)
+		if upd.UpdateID == 1 {
+			panic("boom")
+		}
+	})
+	jobs := make(chan tgbotapi.Update, 2)
+	jobs <- messageUpdate(1, 7)
+	jobs <- messageUpdate(2, 7)
+	close(jobs)
+	pool.Run(context.Background(), jobs)
+
+	if len(handled) != 2 || handled[1] != 2 {
+		t.Fatalf("handled %v, want [1 2]", handled)
+	}
+}
+
+func TestChatKey(t *testing.T) {
+	inline := tgbotapi.Update{UpdateID: 5, CallbackQuery: &tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 42}}}
+	cases := []struct {
+		name string
+		upd  tgbotapi.Update
+		want int64
+	}{
+		{"message", messageUpdate(1, -100500), -100500},
+		{"callback", callbackUpdate(2, 300), 300},
+		{"inline callback", inline, 42},
+		{"channel post", tgbotapi.Update{UpdateID: 3, ChannelPost: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -1001}}}, -1001},
+		{"no chat", tgbotapi.Update{UpdateID: 4}, 4},
+	}
+	for _, c := range cases {
+		if got := chatKey(c.upd); got != c.want {
+			t.Errorf("%s: chatKey = %d, want %d", c.name, got, c.want)
+		}
+	}
+}
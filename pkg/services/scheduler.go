@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/models"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Как часто проверяем, не началась ли какая-нибудь кампания
+const schedulerInterval = 30 * time.Second
+
+const defaultAnnounceText = "🎉 Стартовал розыгрыш <b>%s</b>!\nЖми кнопку и забирай свой трофей!"
+
+// Scheduler следит за окнами кампаний и анонсирует старт всем bot_users
+// через системную рассылку Broadcaster
+type Scheduler struct {
+	repo        *repositories.Repository
+	broadcaster *Broadcaster
+}
+
+func NewScheduler(repo *repositories.Repository, broadcaster *Broadcaster) *Scheduler {
+	return &Scheduler{repo: repo, broadcaster: broadcaster}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ctx = WithPriority(ctx, PriorityBulk)
+	t := time.NewTicker(schedulerInterval)
+	defer t.Stop()
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	dbctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Пометка «анонсировано» и создание рассылки — в одной транзакции,
+	// чтобы рестарт между ними не потерял анонс
+	var announced int
+	err := s.repo.WithTx(dbctx, func(tx *repositories.Repository) error {
+		started, err := tx.TakeCampaignsToAnnounce(dbctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range started {
+			text, markup := announcement(c)
+			if _, err := tx.CreateTextBroadcast(dbctx, text, markup); err != nil {
+				return err
+			}
+			log.Printf("campaign %d started, announcement queued", c.ID)
+		}
+		announced = len(started)
+		return nil
+	})
+	if err != nil {
+		log.Println("announce campaigns:", err)
+		return
+	}
+	if announced > 0 {
+		s.broadcaster.Wake()
+	}
+}
+
+func announcement(c models.Campaign) (string, []byte) {
+	text := c.AnnounceText
+	if text == "" {
+		text = fmt.Sprintf(defaultAnnounceText, html.EscapeString(c.Name))
+	}
+	mk := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Получить стикерпак", "draw"),
+		))
+	markup, _ := json.Marshal(mk)
+	return text, markup
+}
@@ -2,33 +2,249 @@ package services
 
 import (
 	"context"
+	"errors"
+	"log"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/metrics"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"golang.org/x/time/rate"
 )
 
+const (
+	// дольше retry_after внутри Send не ждём — вызывающий повторит сам
+	maxRetryAfter = 30 * time.Second
+	// после 429 лимитер работает вполсилы, пока столько времени не будет новых 429;
+	// снижаем его не чаще раза за это время, чтобы пачка 429 не обрушила лимит до минимума
+	floodCooldown = time.Minute
+	minSendRate   = rate.Limit(1)
+)
+
 type Sender struct {
-	bot *tgbotapi.BotAPI
-	lim *rate.Limiter
+	bot   *tgbotapi.BotAPI
+	lim   *rate.Limiter
+	repo  *repositories.Repository
+	base  rate.Limit // лимит без учёта 429
+	chats *chatLimiter
+	lanes *lanes
+
+	mu          sync.Mutex
+	pausedUntil time.Time // retry_after 429 на вызов без чата: до этого момента Telegram не зовём
+	slowedAt    time.Time // когда последний раз снизили лимит
+	floodAt     time.Time // последний 429
+
+	lastOK, lastErr atomic.Int64 // unix nano последнего ответа Telegram и последнего сбоя (сеть, 5xx)
 }
 
-func NewSender(bot *tgbotapi.BotAPI, lim *rate.Limiter) *Sender {
-	return &Sender{bot: bot, lim: lim}
+func NewSender(bot *tgbotapi.BotAPI, lim *rate.Limiter, repo *repositories.Repository) *Sender {
+	return &Sender{bot: bot, lim: lim, repo: repo, base: lim.Limit(), chats: newChatLimiter(), lanes: newLanes(lim)}
 }
 
-// Глобальный лимит на любой исходящий вызов
+// Глобальный лимит на любой исходящий вызов. Ждёт в полосе из ctx (WithPriority):
+// интерактивный вызов — не дольше 5 с, фоновый — сколько потребуется.
 func (s *Sender) Wait(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-	return s.lim.Wait(ctx)
+	if err := s.waitFlood(ctx); err != nil {
+		return err
+	}
+	p := priorityFrom(ctx)
+	if p == PriorityInteractive {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+	}
+	start := time.Now()
+	err := s.lanes.wait(ctx, p)
+	metrics.SenderWait.WithLabelValues(laneNames[p]).Observe(time.Since(start).Seconds())
+	return err
 }
 
-// Отправка сообщений (Chattable)
+// waitFlood ждёт конец паузы после 429 и возвращает лимиту полную скорость,
+// если 429 давно не было
+func (s *Sender) waitFlood(ctx context.Context) error {
+	s.mu.Lock()
+	pause := time.Until(s.pausedUntil)
+	if !s.slowedAt.IsZero() && time.Since(s.floodAt) >= floodCooldown {
+		s.slowedAt = time.Time{}
+		s.lim.SetLimit(s.base)
+		log.Printf("sender: rate limit restored to %.0f rps", float64(s.base))
+	}
+	s.mu.Unlock()
+	if pause <= 0 {
+		return nil
+	}
+	t := time.NewTimer(pause)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttle — Telegram ответил 429: пауза на retry_after только для этого чата
+// (для вызова без чата — для всех) и вдвое меньший общий лимит, не чаще раза в floodCooldown
+func (s *Sender) throttle(chatID int64, retryAfter time.Duration) {
+	s.chats.Pause(chatID, retryAfter)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if chatID == 0 {
+		if until := now.Add(retryAfter); until.After(s.pausedUntil) {
+			s.pausedUntil = until
+		}
+	}
+	s.floodAt = now
+	if !s.slowedAt.IsZero() && now.Sub(s.slowedAt) < floodCooldown {
+		return
+	}
+	lim := max(s.lim.Limit()/2, minSendRate)
+	s.lim.SetLimit(lim)
+	s.slowedAt = now
+	log.Printf("sender: flood wait %s in chat %d, rate limit lowered to %.1f rps", retryAfter, chatID, float64(lim))
+}
+
+// Send отправляет сообщение (Chattable) с учётом лимита чата и общего лимита. 429 повторяет после retry_after,
+// в мигрировавшую супергруппу — по новому ID. На 403 пользователь помечается
+// заблокировавшим бота. Ошибка возвращается, если отправить так и не удалось.
 func (s *Sender) Send(ctx context.Context, msg tgbotapi.Chattable) (tgbotapi.Message, error) {
-	if err := s.Wait(ctx); err != nil {
-		var empty tgbotapi.Message
-		return empty, err
+	for attempt := 0; ; attempt++ {
+		// сначала очередь чата, потом общий лимит: медленный чат не держит общий токен
+		chatID, _ := ChatID(msg)
+		if err := s.chats.Wait(ctx, chatID); err != nil {
+			var empty tgbotapi.Message
+			return empty, err
+		}
+		if err := s.Wait(ctx); err != nil {
+			var empty tgbotapi.Message
+			return empty, err
+		}
+		m, err := s.bot.Send(msg)
+		if apiReachable(err) {
+			s.lastOK.Store(time.Now().UnixNano())
+		} else {
+			s.lastErr.Store(time.Now().UnixNano())
+		}
+		observeAPIError(err)
+
+		var tgErr *tgbotapi.Error
+		if !errors.As(err, &tgErr) || attempt >= 2 {
+			return m, err
+		}
+		switch {
+		case tgErr.Code == 429:
+			retryAfter := time.Duration(max(tgErr.RetryAfter, 1)) * time.Second
+			s.throttle(chatID, retryAfter)
+			if retryAfter <= maxRetryAfter {
+				continue
+			}
+		case tgErr.MigrateToChatID != 0:
+			s.migrateChat(ctx, chatID, tgErr.MigrateToChatID)
+			if migrated, ok := withChatID(msg, tgErr.MigrateToChatID); ok {
+				msg = migrated
+				continue
+			}
+		case tgErr.Code == 403 && chatID > 0:
+			s.markBlocked(ctx, chatID)
+		}
+		return m, err
+	}
+}
+
+func (s *Sender) markBlocked(ctx context.Context, userID int64) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if err := s.repo.MarkUserBlocked(dbctx, userID); err != nil {
+		log.Println("MarkUserBlocked:", err)
+	}
+}
+
+func (s *Sender) migrateChat(ctx context.Context, from, to int64) {
+	log.Printf("sender: chat %d migrated to supergroup %d", from, to)
+	if from == 0 {
+		return
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := s.repo.MigrateChat(dbctx, from, to); err != nil {
+		log.Println("MigrateChat:", err)
+	}
+}
+
+// ChatID — получатель сообщения: поле ChatID из BaseChat/BaseEdit любого конфига
+func ChatID(msg tgbotapi.Chattable) (int64, bool) {
+	v := reflect.ValueOf(msg)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return 0, false
+	}
+	f := v.FieldByName("ChatID")
+	if !f.IsValid() || f.Kind() != reflect.Int64 {
+		return 0, false
+	}
+	return f.Int(), true
+}
+
+// withChatID — копия сообщения с другим получателем
+func withChatID(msg tgbotapi.Chattable, chatID int64) (tgbotapi.Chattable, bool) {
+	v := reflect.ValueOf(msg)
+	if v.Kind() != reflect.Struct {
+		return nil, false
+	}
+	cp := reflect.New(v.Type()).Elem()
+	cp.Set(v)
+	f := cp.FieldByName("ChatID")
+	if !f.IsValid() || f.Kind() != reflect.Int64 || !f.CanSet() {
+		return nil, false
+	}
+	f.SetInt(chatID)
+	out, ok := cp.Interface().(tgbotapi.Chattable)
+	return out, ok
+}
+
+// LastSuccess — время последнего ответа Telegram API, в том числе ошибки 4xx; нулевое — вызовов не было
+func (s *Sender) LastSuccess() time.Time {
+	return unixNano(s.lastOK.Load())
+}
+
+// LastError — время последнего сбоя Telegram API: сеть или 5xx
+func (s *Sender) LastError() time.Time {
+	return unixNano(s.lastErr.Load())
+}
+
+func unixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// apiReachable — Telegram ответил по существу. 4xx (бот заблокирован, кривая разметка,
+// 429) — обычный ответ API, а не его недоступность.
+func apiReachable(err error) bool {
+	if err == nil {
+		return true
+	}
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && tgErr.Code >= 400 && tgErr.Code < 500
+}
+
+func observeAPIError(err error) {
+	if err == nil {
+		return
+	}
+	code := "network"
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) {
+		code = strconv.Itoa(tgErr.Code)
 	}
-	return s.bot.Send(msg)
+	metrics.TelegramErrors.WithLabelValues(code).Inc()
 }
@@ -9,24 +9,124 @@ import (
 
 var ErrAlreadyClaimed = errors.New("already_claimed")
 
+// errSoldOut — выбранный пак успели распродать параллельно, выбираем другой
+var errSoldOut = errors.New("sold_out")
+
+// Сколько раз перевыбираем пак, если выбранный успели распродать параллельно
+const maxReserveAttempts = 5
+
+// TestDrawCode — код, который видит админ в тестовом розыгрыше вместо настоящего
+const TestDrawCode = "TEST-CODE"
+
 type Service struct {
-	Repo *repositories.Repository
+	Repo   *repositories.Repository
+	admins adminCache
 }
 
 func NewService(repo *repositories.Repository) *Service {
 	return &Service{Repo: repo}
 }
 
-func (s *Service) ClaimStickerPack(ctx context.Context, userID, adminID int64) (models.StickerPack, error) {
-	// Админ может дергать бесконечно
-	if userID != adminID {
-		ok, err := s.Repo.TryClaim(ctx, userID)
+// ClaimStickerPack резервирует попытку пользователя, выбирает пак, списывает
+// остаток/код и записывает выигрыш в одной транзакции. Любая ошибка (в том
+// числе ErrNoPacks) откатывает её целиком, и попытка пользователя не сгорает.
+// У возвращённого пака Stock и FreeCodes — остатки после списания, Code — выданный код.
+// unlimited (тестовый розыгрыш админа) — без ограничения на одну попытку, без записи
+// клейма и без списания: остаток не меняется, вместо кода — TestDrawCode.
+// onWin вызывается в той же транзакции (например, поставить сообщения о призе в outbox):
+// его ошибка тоже откатывает клейм.
+func (s *Service) ClaimStickerPack(ctx context.Context, campaignID int, userID int64, unlimited bool,
+	onWin func(tx *repositories.Repository, p models.StickerPack) error) (models.StickerPack, error) {
+	var won models.StickerPack
+	err := s.Repo.WithTx(ctx, func(tx *repositories.Repository) error {
+		// Админ может дергать бесконечно
+		if !unlimited {
+			ok, err := tx.TryClaim(ctx, campaignID, userID)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ErrAlreadyClaimed
+			}
+		}
+
+		var tried []int
+		for i := 0; i < maxReserveAttempts; i++ {
+			p, err := tx.GetRandomStickerPack(ctx, campaignID, tried)
+			if err != nil {
+				return err
+			}
+			if unlimited {
+				if p.Kind == models.PackKindCodes {
+					p.Code = TestDrawCode
+				}
+				won = p
+				if onWin != nil {
+					return onWin(tx, p)
+				}
+				return nil
+			}
+			// Каждая попытка — в своём savepoint, чтобы неудачная не оставила
+			// списанный остаток или выданный код
+			err = tx.WithTx(ctx, func(sp *repositories.Repository) error {
+				return reservePack(ctx, sp, &p, userID)
+			})
+			if errors.Is(err, errSoldOut) {
+				tried = append(tried, p.ID)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := tx.SetClaimPack(ctx, campaignID, userID, p.ID); err != nil {
+				return err
+			}
+			won = p
+			if onWin != nil {
+				return onWin(tx, p)
+			}
+			return nil
+		}
+		return repositories.ErrNoPacks
+	})
+	if err != nil {
+		return models.StickerPack{}, err
+	}
+	return won, nil
+}
+
+func reservePack(ctx context.Context, tx *repositories.Repository, p *models.StickerPack, userID int64) error {
+	if p.Kind == models.PackKindCodes {
+		code, ok, err := tx.ClaimCode(ctx, p.ID, userID)
 		if err != nil {
-			return models.StickerPack{}, err
+			return err
 		}
 		if !ok {
-			return models.StickerPack{}, ErrAlreadyClaimed
+			return errSoldOut
 		}
+		free, err := tx.CountFreeCodes(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		p.Code, p.FreeCodes = code, free
+	}
+	if p.Stock != nil {
+		remaining, ok, err := tx.ReserveStock(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errSoldOut
+		}
+		p.Stock = &remaining
+	}
+	return nil
+}
+
+// SoldOut — после этого клейма пак больше не может выпасть
+func SoldOut(p models.StickerPack) bool {
+	if p.Stock != nil && *p.Stock == 0 {
+		return true
 	}
-	return s.Repo.GetRandomStickerPack(ctx)
+	return p.Kind == models.PackKindCodes && p.FreeCodes == 0
 }
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/metrics"
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/repositories"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	updateDrainIdle = time.Second // как часто проверяем очередь в БД без Wake
+	updateDrainMax  = 500         // сколько апдейтов поднимаем из БД за раз
+	// аренда апдейта экземпляром; продлевается, пока экземпляр жив
+	updateLease      = time.Minute
+	updateLeaseRenew = 20 * time.Second
+	// обработанные update_id храним для дедупликации: дольше суток Telegram апдейт не повторяет
+	updateKeep       = 24 * time.Hour
+	updatePurgeEvery = time.Hour
+)
+
+// UpdateQueue — очередь апдейтов для воркеров. Каждый апдейт сначала пишется в update_queue
+// (повторный update_id отбрасывается), затем Run забирает его из БД в канал в памяти —
+// по порядку update_id и по мере того, как в канале есть место. Всплеск так только
+// задерживается в БД, а приём апдейтов не ждёт ни воркеров, ни чужих запросов к БД.
+// Апдейты в работе арендованы экземпляром (owner, lease_until): при остановке он
+// отдаёт необработанное (Release), а после падения его апдейты заберут по истечении аренды.
+type UpdateQueue struct {
+	repo  *repositories.Repository
+	owner string
+	jobs  chan tgbotapi.Update
+	wake  chan struct{}
+
+	spilled atomic.Bool // канал был полон: апдейты ждут в БД
+}
+
+func NewUpdateQueue(repo *repositories.Repository, size int) *UpdateQueue {
+	return &UpdateQueue{
+		repo:  repo,
+		owner: instanceID(),
+		jobs:  make(chan tgbotapi.Update, size),
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// instanceID — имя экземпляра для аренды апдейтов: хост плюс случайный суффикс,
+// у перезапущенного контейнера он другой
+func instanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// Jobs — апдейты для воркеров; после обработки воркер вызывает Done
+func (q *UpdateQueue) Jobs() <-chan tgbotapi.Update { return q.jobs }
+
+// Len — заполненность очереди в памяти (для /readyz)
+func (q *UpdateQueue) Len() (int, int) { return len(q.jobs), cap(q.jobs) }
+
+// Push сохраняет апдейт в очередь. Ошибка — апдейт не сохранён (БД недоступна);
+// вебхук тогда не отвечает 200, и Telegram повторит его.
+func (q *UpdateQueue) Push(ctx context.Context, upd tgbotapi.Update) error {
+	payload, err := json.Marshal(upd)
+	if err != nil {
+		return err
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	fresh, err := q.repo.EnqueueUpdate(dbctx, upd.UpdateID, payload)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		metrics.UpdatesDuplicate.Inc()
+		return nil
+	}
+	if len(q.jobs) == cap(q.jobs) {
+		metrics.UpdatesSpilled.Inc()
+		if !q.spilled.Swap(true) {
+			log.Println("update queue: workers are busy, updates wait in the database")
+		}
+	}
+	q.Wake()
+	return nil
+}
+
+// Offer — апдейт, который не удалось сохранить: отдать воркерам, если есть место.
+// Только для long polling — Telegram его уже не повторит.
+func (q *UpdateQueue) Offer(upd tgbotapi.Update) {
+	select {
+	case q.jobs <- upd:
+	default:
+		metrics.UpdatesDropped.Inc()
+		log.Println("updates backlog overflow, dropping update")
+	}
+}
+
+// Done — апдейт обработан, повторно его не выдадим
+func (q *UpdateQueue) Done(ctx context.Context, updateID int) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if err := q.repo.MarkUpdateProcessed(dbctx, updateID); err != nil {
+		log.Println("MarkUpdateProcessed:", err)
+	}
+}
+
+// Release отдаёт другим экземплярам всё, что этот взял, но не обработал.
+// Вызывается при остановке, когда воркеры уже остановлены.
+func (q *UpdateQueue) Release(ctx context.Context) {
+	dbctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	n, err := q.repo.ReleaseUpdates(dbctx, q.owner)
+	if err != nil {
+		log.Println("ReleaseUpdates:", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("update queue: released %d unprocessed updates", n)
+	}
+}
+
+func (q *UpdateQueue) Wake() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run забирает из БД ожидающие апдейты (новые, отпущенные и брошенные упавшим
+// экземпляром) по мере освобождения места, продлевает аренду и чистит старые обработанные
+func (q *UpdateQueue) Run(ctx context.Context) {
+	t := time.NewTicker(updateDrainIdle)
+	defer t.Stop()
+	var lastPurge, lastRenew time.Time
+	for {
+		for q.drain(ctx) {
+		}
+		if time.Since(lastRenew) >= updateLeaseRenew {
+			lastRenew = time.Now()
+			q.renew(ctx)
+		}
+		if time.Since(lastPurge) >= updatePurgeEvery {
+			lastPurge = time.Now()
+			q.purge(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// drain поднимает из БД столько апдейтов, сколько помещается в канал. Смотрим в БД
+// на каждом тике, даже без Wake: аренда упавшего экземпляра истекает сама.
+// true — ожидающие ещё есть и место в канале осталось, стоит повторить сразу.
+func (q *UpdateQueue) drain(ctx context.Context) bool {
+	free := min(cap(q.jobs)-len(q.jobs), updateDrainMax)
+	if free == 0 {
+		return false
+	}
+
+	dbctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	payloads, err := q.repo.ClaimUpdates(dbctx, q.owner, updateLease, free)
+	cancel()
+	if err != nil {
+		log.Println("ClaimUpdates:", err)
+		return false
+	}
+	// в канал пишет только drain (и Offer, когда БД недоступна), поэтому место
+	// почти всегда есть; иначе ждём воркеров. При остановке взятое отдаст Release.
+	for _, p := range payloads {
+		var upd tgbotapi.Update
+		if err := json.Unmarshal(p, &upd); err != nil {
+			log.Println("update queue: unmarshal:", err)
+			continue
+		}
+		select {
+		case q.jobs <- upd:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	if len(payloads) < free {
+		// всё ожидающее отдано
+		if q.spilled.Swap(false) {
+			log.Println("update queue: caught up with the database")
+		}
+		return false
+	}
+	return true
+}
+
+func (q *UpdateQueue) renew(ctx context.Context) {
+	dbctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := q.repo.RenewUpdateLeases(dbctx, q.owner, updateLease); err != nil {
+		log.Println("RenewUpdateLeases:", err)
+	}
+}
+
+func (q *UpdateQueue) purge(ctx context.Context) {
+	dbctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	n, err := q.repo.PurgeProcessedUpdates(dbctx, updateKeep)
+	if err != nil {
+		log.Println("PurgeProcessedUpdates:", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("update queue: purged %d processed updates", n)
+	}
+}
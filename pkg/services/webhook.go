@@ -0,0 +1,99 @@
+package services
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/Redarek/go-tg-bot-lucky-prizes/pkg/metrics"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Больше апдейт от Telegram не бывает
+const maxWebhookBody = 1 << 20
+
+// Webhook принимает апдейты от Telegram по HTTPS и кладёт их в очередь апдейтов.
+// 200 отвечаем только после записи в БД: иначе Telegram повторит апдейт.
+// Запросы без нашего secret_token отбрасываются.
+type Webhook struct {
+	bot    *tgbotapi.BotAPI
+	url    string
+	secret string
+	queue  *UpdateQueue
+}
+
+func NewWebhook(bot *tgbotapi.BotAPI, webhookURL, secret string, queue *UpdateQueue) *Webhook {
+	return &Webhook{
+		bot:    bot,
+		url:    webhookURL,
+		secret: secret,
+		queue:  queue,
+	}
+}
+
+// Path — путь из WEBHOOK_URL, на котором слушать
+func (w *Webhook) Path() string {
+	u, err := url.Parse(w.url)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// Register регистрирует вебхук. В этой версии библиотеки у WebhookConfig нет
+// secret_token, поэтому запрос собираем сами.
+func (w *Webhook) Register(allowedUpdates []string) error {
+	allowed, err := json.Marshal(allowedUpdates)
+	if err != nil {
+		return err
+	}
+	resp, err := w.bot.MakeRequest("setWebhook", tgbotapi.Params{
+		"url":             w.url,
+		"secret_token":    w.secret,
+		"allowed_updates": string(allowed),
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("setWebhook: %s", resp.Description)
+	}
+	return nil
+}
+
+// Unregister снимает вебхук; накопившиеся апдейты Telegram сохранит до следующего запуска
+func (w *Webhook) Unregister() error {
+	_, err := w.bot.Request(tgbotapi.DeleteWebhookConfig{})
+	return err
+}
+
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	got := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(w.secret)) != 1 {
+		http.Error(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var upd tgbotapi.Update
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxWebhookBody)).Decode(&upd); err != nil {
+		log.Println("webhook: bad update:", err)
+		http.Error(rw, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	metrics.UpdatesReceived.WithLabelValues("webhook").Inc()
+	if err := w.queue.Push(r.Context(), upd); err != nil {
+		// не ответили 200 — Telegram пришлёт апдейт ещё раз
+		log.Println("webhook: enqueue update:", err)
+		http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+}